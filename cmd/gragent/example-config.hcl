@@ -1,6 +1,14 @@
 scrape_interval = "60s"
 scrape_timeout  = "10s"
 
+// Declare an argument for where to send metrics. The default can be
+// overridden per environment with -config.arg remote_write_url=<url> or
+// through a file passed to -config.args-file.
+argument "remote_write_url" {
+  type    = string
+  default = "http://localhost:9009/api/prom/push"
+}
+
 // Create list of static discovery jobs. Each of these is a component with its
 // own state that can be referenced from other components.
 discovery "static" "robustperception-grafana" {
@@ -45,8 +53,12 @@ scrape "robustperception" {
   // be replaced by doing the concat of the individual SDs here, but we still do
   // this as an example of chaining.
   targets = discovery.chain.robustperception.targets
+
+  // Send scraped metrics to remote_write.default. Any number of receivers may
+  // be provided.
+  forward_to = [remote_write.default.receiver]
 }
 
 remote_write "default" {
-  url = "http://localhost:9009/api/prom/push"
+  url = argument.remote_write_url
 }
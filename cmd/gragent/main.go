@@ -6,16 +6,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/gorilla/mux"
+	"github.com/hashicorp/hcl/v2"
 
 	"github.com/rfratto/gragent/internal/gragent"
 )
@@ -34,11 +37,19 @@ func run() error {
 	var (
 		httpListenAddr = ":8080"
 		configFile     string
+		configArgs     = argumentsFlag{}
+		configArgsFile string
+		funcsVersion   int
+		dataPath       = "data-gragent/"
 	)
 
 	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	fs.StringVar(&httpListenAddr, "server.http-listen-addr", httpListenAddr, "address to listen for http traffic on")
 	fs.StringVar(&configFile, "config.file", configFile, "path to config file to load")
+	fs.Var(configArgs, "config.arg", "value for a config argument as name=value. May be repeated")
+	fs.StringVar(&configArgsFile, "config.args-file", configArgsFile, "path to an HCL or JSON file holding values for config arguments")
+	fs.IntVar(&funcsVersion, "config.functions-version", funcsVersion, "version of the function table to use for the config file. Defaults to the latest version")
+	fs.StringVar(&dataPath, "storage.path", dataPath, "directory where components store data, such as remote_write WALs")
 
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		return fmt.Errorf("error parsing flags: %w", err)
@@ -50,9 +61,20 @@ func run() error {
 	}
 
 	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
-	s := gragent.NewSystem(l, configFile)
+	s := gragent.NewSystem(l, gragent.Options{
+		ConfigFile:       configFile,
+		DataPath:         dataPath,
+		Arguments:        configArgs,
+		ArgumentsFile:    configArgsFile,
+		FunctionsVersion: funcsVersion,
+	})
 
 	if err := s.Load(); err != nil {
+		// Write out every diagnostic; the error string only includes the first.
+		var diags hcl.Diagnostics
+		if errors.As(err, &diags) {
+			_ = hcl.NewDiagnosticTextWriter(os.Stderr, s.Files(), 80, false).WriteDiagnostics(diags)
+		}
 		return fmt.Errorf("error during the initial gragent load: %w", err)
 	}
 
@@ -65,6 +87,8 @@ func run() error {
 
 		r := mux.NewRouter()
 		r.Handle("/graph", s.GraphHandler())
+		r.Handle("/status", s.StatusHandler())
+		r.Handle("/-/reload", s.ReloadHandler()).Methods(http.MethodPost)
 
 		go func() {
 			defer cancel()
@@ -77,7 +101,9 @@ func run() error {
 	}
 
 	// Gragent
+	exited := make(chan struct{})
 	go func() {
+		defer close(exited)
 		defer cancel()
 		if err := s.Run(ctx); err != nil {
 			level.Error(l).Log("msg", "error while running gragent", "err", err)
@@ -85,6 +111,28 @@ func run() error {
 	}()
 
 	<-ctx.Done()
+	<-exited // Wait for components to stop
+	return nil
+}
+
+// argumentsFlag is a flag.Value which collects name=value pairs. Setting
+// the same name more than once keeps the last value.
+type argumentsFlag map[string]string
+
+func (f argumentsFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for name, value := range f {
+		pairs = append(pairs, name+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f argumentsFlag) Set(in string) error {
+	parts := strings.SplitN(in, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("expected name=value, got %q", in)
+	}
+	f[parts[0]] = parts[1]
 	return nil
 }
 
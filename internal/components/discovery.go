@@ -0,0 +1,192 @@
+package components
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+// UpdatableDiscoverer is a discovery.Discoverer which can apply a new config
+// without being restarted.
+type UpdatableDiscoverer interface {
+	discovery.Discoverer
+
+	// ApplyConfig applies cfg to the running discoverer. ApplyConfig returns
+	// false if cfg can't be applied, in which case the discoverer will be
+	// recreated.
+	ApplyConfig(cfg discovery.Config) bool
+}
+
+// Discovery runs a Prometheus discoverer and tracks the most recent set of
+// target groups it discovered.
+type Discovery struct {
+	logger log.Logger
+
+	configMut    sync.Mutex
+	cfg          discovery.Config
+	reloadConfig chan struct{}
+
+	groupsMut sync.RWMutex
+	groups    map[string]*targetgroup.Group // Target groups by source
+	emitted   int
+}
+
+// NewDiscovery creates a new, unstarted Discovery.
+func NewDiscovery(l log.Logger) *Discovery {
+	return &Discovery{
+		logger: log.With(l, "component", "discovery"),
+
+		reloadConfig: make(chan struct{}, 1),
+		groups:       make(map[string]*targetgroup.Group),
+	}
+}
+
+// Configure sets the discovery config to run. The discoverer from the
+// previous config will be stopped if cfg is different, unless it is an
+// UpdatableDiscoverer which accepts cfg.
+func (d *Discovery) Configure(cfg discovery.Config) {
+	d.configMut.Lock()
+	defer d.configMut.Unlock()
+
+	// Reloading gragent re-applies the config of every component. Avoid
+	// restarting the discoverer when nothing changed.
+	if d.cfg != nil && reflect.DeepEqual(d.cfg, cfg) {
+		return
+	}
+
+	// Store our most recent config
+	d.cfg = cfg
+
+	select {
+	case d.reloadConfig <- struct{}{}:
+	default:
+		// Something is already queued, don't need to do anything
+	}
+}
+
+// Run runs Discovery until ctx is canceled. updated will be invoked every
+// time the discoverer sends new target groups.
+func (d *Discovery) Run(ctx context.Context, updated func()) {
+	var (
+		current        discovery.Discoverer
+		stopDiscoverer = func() {}
+		up             chan []*targetgroup.Group
+	)
+	defer func() { stopDiscoverer() }()
+
+	// Configure may have been called before Run, or before a previous Run
+	// exited and stopped its discoverer. Start a discoverer for that config.
+	d.configMut.Lock()
+	if d.cfg != nil {
+		select {
+		case d.reloadConfig <- struct{}{}:
+		default:
+		}
+	}
+	d.configMut.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-d.reloadConfig:
+			// Grab the config out of the mutex
+			d.configMut.Lock()
+			cfg := d.cfg
+			d.configMut.Unlock()
+
+			// Apply the config in place if the running discoverer supports it.
+			// It's responsible for informing us of any changes.
+			if ud, ok := current.(UpdatableDiscoverer); ok && ud.ApplyConfig(cfg) {
+				continue
+			}
+
+			disc, err := cfg.NewDiscoverer(discovery.DiscovererOptions{Logger: d.logger})
+			if err != nil {
+				level.Error(d.logger).Log("msg", "failed to create discoverer", "err", err)
+				continue
+			}
+
+			// Stop the old discoverer and forget about its groups. The new
+			// discoverer is expected to send its full set of groups on startup,
+			// which will inform our caller of the change.
+			stopDiscoverer()
+			d.resetGroups()
+
+			discCtx, cancel := context.WithCancel(ctx)
+			stopDiscoverer = cancel
+			up = make(chan []*targetgroup.Group)
+			current = disc
+			go disc.Run(discCtx, up)
+
+		case groups, ok := <-up:
+			if !ok {
+				// Some discoverers, like the static discoverer, close the channel
+				// once they're done sending. Stop receiving from it so we don't
+				// spin on the closed channel.
+				up = nil
+				continue
+			}
+			d.updateGroups(groups)
+			updated()
+		}
+	}
+}
+
+func (d *Discovery) resetGroups() {
+	d.groupsMut.Lock()
+	defer d.groupsMut.Unlock()
+	d.groups = make(map[string]*targetgroup.Group)
+}
+
+// updateGroups merges groups into the current set of groups. Like Prometheus,
+// groups are identified by their source, and a group with no targets removes
+// the group with the same source.
+func (d *Discovery) updateGroups(groups []*targetgroup.Group) {
+	d.groupsMut.Lock()
+	defer d.groupsMut.Unlock()
+
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+		d.emitted++
+
+		if len(group.Targets) == 0 {
+			delete(d.groups, group.Source)
+			continue
+		}
+		d.groups[group.Source] = group
+	}
+}
+
+// Targets returns the current set of discovered target groups, sorted by
+// source.
+func (d *Discovery) Targets() []*targetgroup.Group {
+	d.groupsMut.RLock()
+	defer d.groupsMut.RUnlock()
+
+	groups := make([]*targetgroup.Group, 0, len(d.groups))
+	for _, group := range d.groups {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Source < groups[j].Source
+	})
+	return groups
+}
+
+// EmittedGroups returns the total number of target groups sent by
+// discoverers.
+func (d *Discovery) EmittedGroups() int {
+	d.groupsMut.RLock()
+	defer d.groupsMut.RUnlock()
+	return d.emitted
+}
@@ -0,0 +1,88 @@
+package components
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	tsdb_errors "github.com/prometheus/prometheus/tsdb/errors"
+)
+
+// Fanout is a storage.Appendable which sends appended data to a set of
+// children. The set of children may be changed at any time; changes take
+// effect for the next call to Appender.
+type Fanout struct {
+	mut      sync.RWMutex
+	children []storage.Appendable
+}
+
+var _ storage.Appendable = (*Fanout)(nil)
+
+// UpdateChildren replaces the set of children that data is sent to.
+func (f *Fanout) UpdateChildren(children []storage.Appendable) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.children = children
+}
+
+// Appender implements storage.Appendable.
+func (f *Fanout) Appender(ctx context.Context) storage.Appender {
+	f.mut.RLock()
+	defer f.mut.RUnlock()
+
+	app := &fanoutAppender{
+		children: make([]storage.Appender, 0, len(f.children)),
+	}
+	for _, child := range f.children {
+		app.children = append(app.children, child.Appender(ctx))
+	}
+	return app
+}
+
+type fanoutAppender struct {
+	children []storage.Appender
+}
+
+var _ storage.Appender = (*fanoutAppender)(nil)
+
+// Append implements storage.Appender. Series references can't be shared
+// across children, so the returned reference is always 0.
+func (a *fanoutAppender) Append(_ storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	errs := tsdb_errors.NewMulti()
+	for _, child := range a.children {
+		_, err := child.Append(0, l, t, v)
+		errs.Add(err)
+	}
+	return 0, errs.Err()
+}
+
+// AppendExemplar implements storage.Appender. Series references can't be
+// shared across children, so the returned reference is always 0.
+func (a *fanoutAppender) AppendExemplar(_ storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	errs := tsdb_errors.NewMulti()
+	for _, child := range a.children {
+		_, err := child.AppendExemplar(0, l, e)
+		errs.Add(err)
+	}
+	return 0, errs.Err()
+}
+
+// Commit implements storage.Appender.
+func (a *fanoutAppender) Commit() error {
+	errs := tsdb_errors.NewMulti()
+	for _, child := range a.children {
+		errs.Add(child.Commit())
+	}
+	return errs.Err()
+}
+
+// Rollback implements storage.Appender.
+func (a *fanoutAppender) Rollback() error {
+	errs := tsdb_errors.NewMulti()
+	for _, child := range a.children {
+		errs.Add(child.Rollback())
+	}
+	return errs.Err()
+}
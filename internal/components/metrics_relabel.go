@@ -0,0 +1,85 @@
+package components
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// MetricsRelabel is a storage.Appendable which applies relabeling rules to
+// appended series before sending them to the next storage.Appendable. Series
+// dropped by the rules are discarded.
+type MetricsRelabel struct {
+	next storage.Appendable
+
+	mut sync.RWMutex
+	rcs []*relabel.Config
+}
+
+var _ storage.Appendable = (*MetricsRelabel)(nil)
+
+// NewMetricsRelabel creates a new MetricsRelabel which sends relabeled data
+// to next.
+func NewMetricsRelabel(next storage.Appendable) *MetricsRelabel {
+	return &MetricsRelabel{next: next}
+}
+
+// UpdateRules replaces the set of relabeling rules. Changes take effect for
+// the next call to Appender.
+func (mr *MetricsRelabel) UpdateRules(rcs []*relabel.Config) {
+	mr.mut.Lock()
+	defer mr.mut.Unlock()
+	mr.rcs = rcs
+}
+
+// Appender implements storage.Appendable.
+func (mr *MetricsRelabel) Appender(ctx context.Context) storage.Appender {
+	mr.mut.RLock()
+	defer mr.mut.RUnlock()
+
+	return &relabelAppender{
+		next: mr.next.Appender(ctx),
+		rcs:  mr.rcs,
+	}
+}
+
+type relabelAppender struct {
+	next storage.Appender
+	rcs  []*relabel.Config
+}
+
+var _ storage.Appender = (*relabelAppender)(nil)
+
+// Append implements storage.Appender. Series references aren't passed
+// through as relabeling may change the series being appended, so the
+// returned reference is always 0.
+func (a *relabelAppender) Append(_ storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	l = relabel.Process(l, a.rcs...)
+	if l == nil {
+		return 0, nil
+	}
+	_, err := a.next.Append(0, l, t, v)
+	return 0, err
+}
+
+// AppendExemplar implements storage.Appender. Series references aren't passed
+// through as relabeling may change the series being appended, so the
+// returned reference is always 0.
+func (a *relabelAppender) AppendExemplar(_ storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	l = relabel.Process(l, a.rcs...)
+	if l == nil {
+		return 0, nil
+	}
+	_, err := a.next.AppendExemplar(0, l, e)
+	return 0, err
+}
+
+// Commit implements storage.Appender.
+func (a *relabelAppender) Commit() error { return a.next.Commit() }
+
+// Rollback implements storage.Appender.
+func (a *relabelAppender) Rollback() error { return a.next.Rollback() }
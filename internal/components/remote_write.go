@@ -2,19 +2,30 @@ package components
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/prometheus/prometheus/tsdb/agent"
 	"github.com/rfratto/gragent/internal/promutils"
 )
 
+// errNotRunning is returned by appenders of a RemoteWrite which isn't
+// running.
+var errNotRunning = errors.New("remote_write is not running")
+
 // RemoteWrite is a thin wrapper around Prometheus remote_write that supports
-// Gragent capabilities.
+// Gragent capabilities. Samples appended to RemoteWrite are written to a
+// Prometheus agent-mode WAL before being sent.
 type RemoteWrite struct {
 	logger       log.Logger
 	reg          prometheus.Registerer
@@ -23,18 +34,46 @@ type RemoteWrite struct {
 	configMut sync.Mutex
 	rwc       *config.RemoteWriteConfig
 	reloadRWC chan struct{}
+
+	dbMut sync.RWMutex
+	db    *agent.DB // Only set while running
+
+	sendErrors *errorRecorder
+
+	metricsMut sync.Mutex
+	metrics    prometheus.Gatherer // Only set while running
 }
 
-// NewRemoteWrite creates a new, unstarted remote_write.
+// RemoteWriteStatus holds debug information about a RemoteWrite.
+type RemoteWriteStatus struct {
+	// Shards is the number of shards currently used to send samples.
+	Shards int
+	// PendingSamples is the number of samples waiting to be sent.
+	PendingSamples int
+
+	// LastSendError is the last error encountered while sending samples,
+	// along with the time it happened. LastSendError is nil if sending has
+	// never failed.
+	LastSendError     error
+	LastSendErrorTime time.Time
+}
+
+var _ storage.Appendable = (*RemoteWrite)(nil)
+
+// NewRemoteWrite creates a new, unstarted remote_write. The WAL will be stored
+// in walDir.
 func NewRemoteWrite(l log.Logger, name, walDir string) *RemoteWrite {
+	logger := log.With(l, "component", "remote_write")
+
 	return &RemoteWrite{
-		logger: log.With(l, "component", "remote_write"),
+		logger: logger,
 		reg:    prometheus.WrapRegistererWith(prometheus.Labels{"remote_write": name}, prometheus.DefaultRegisterer),
 
 		name:   name,
 		walDir: walDir,
 
-		reloadRWC: make(chan struct{}, 1),
+		reloadRWC:  make(chan struct{}, 1),
+		sendErrors: &errorRecorder{next: logger},
 	}
 }
 
@@ -62,9 +101,38 @@ func (rw *RemoteWrite) Run(ctx context.Context, updated func()) {
 	ureg := promutils.WrapWithUnregisterer(rw.reg)
 	defer ureg.UnregisterAll()
 
-	rs := remote.NewStorage(rw.logger, ureg, fakeStartTimeCallback, rw.walDir, 30*time.Second, nil)
+	rw.setMetrics(ureg)
+	defer rw.setMetrics(nil)
+
+	// Errors logged by the remote storage are recorded to report the last
+	// error encountered while sending.
+	rs := remote.NewStorage(rw.sendErrors, ureg, rw.startTime, rw.walDir, 30*time.Second, nil)
 	defer rs.Close()
 
+	db, err := agent.Open(rw.logger, ureg, rs, rw.walDir, agent.DefaultOptions())
+	if err != nil {
+		level.Error(rw.logger).Log("msg", "failed to open WAL", "dir", rw.walDir, "err", err)
+		return
+	}
+	defer func() {
+		rw.setDB(nil)
+		if err := db.Close(); err != nil {
+			level.Error(rw.logger).Log("msg", "failed to close WAL", "err", err)
+		}
+	}()
+	rw.setDB(db)
+
+	// The remote storage opened above has no queues yet. Apply any config set
+	// by Configure before Run was called so samples in the WAL get sent.
+	rw.configMut.Lock()
+	if rw.rwc != nil {
+		select {
+		case rw.reloadRWC <- struct{}{}:
+		default:
+		}
+	}
+	rw.configMut.Unlock()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -88,6 +156,133 @@ func (rw *RemoteWrite) Run(ctx context.Context, updated func()) {
 	}
 }
 
-func fakeStartTimeCallback() (int64, error) {
-	return 0, nil
+func (rw *RemoteWrite) setDB(db *agent.DB) {
+	rw.dbMut.Lock()
+	defer rw.dbMut.Unlock()
+	rw.db = db
+}
+
+func (rw *RemoteWrite) setMetrics(g prometheus.Gatherer) {
+	rw.metricsMut.Lock()
+	defer rw.metricsMut.Unlock()
+	rw.metrics = g
+}
+
+// Status returns debug information about rw. Only the last send error is
+// reported while rw isn't running.
+func (rw *RemoteWrite) Status() RemoteWriteStatus {
+	var status RemoteWriteStatus
+	status.LastSendError, status.LastSendErrorTime = rw.sendErrors.LastError()
+
+	rw.metricsMut.Lock()
+	g := rw.metrics
+	rw.metricsMut.Unlock()
+	if g == nil {
+		return status
+	}
+
+	families, err := g.Gather()
+	if err != nil {
+		level.Warn(rw.logger).Log("msg", "failed to gather remote_write metrics", "err", err)
+		return status
+	}
+	for _, mf := range families {
+		switch mf.GetName() {
+		case "prometheus_remote_storage_shards":
+			status.Shards = int(sumGauges(mf))
+		case "prometheus_remote_storage_samples_pending":
+			status.PendingSamples = int(sumGauges(mf))
+		}
+	}
+	return status
+}
+
+// sumGauges returns the sum of all gauges in mf.
+func sumGauges(mf *dto.MetricFamily) float64 {
+	var sum float64
+	for _, m := range mf.GetMetric() {
+		sum += m.GetGauge().GetValue()
+	}
+	return sum
+}
+
+// startTime returns the start time of the WAL.
+func (rw *RemoteWrite) startTime() (int64, error) {
+	rw.dbMut.RLock()
+	defer rw.dbMut.RUnlock()
+
+	if rw.db == nil {
+		return 0, errNotRunning
+	}
+	return rw.db.StartTime()
+}
+
+// Appender implements storage.Appendable. Samples are appended to the WAL.
+// Appends fail while RemoteWrite isn't running.
+func (rw *RemoteWrite) Appender(ctx context.Context) storage.Appender {
+	rw.dbMut.RLock()
+	defer rw.dbMut.RUnlock()
+
+	if rw.db == nil {
+		return notRunningAppender{}
+	}
+	return rw.db.Appender(ctx)
+}
+
+// notRunningAppender is a storage.Appender which fails all appends.
+type notRunningAppender struct{}
+
+func (notRunningAppender) Append(storage.SeriesRef, labels.Labels, int64, float64) (storage.SeriesRef, error) {
+	return 0, errNotRunning
+}
+
+func (notRunningAppender) AppendExemplar(storage.SeriesRef, labels.Labels, exemplar.Exemplar) (storage.SeriesRef, error) {
+	return 0, errNotRunning
+}
+
+func (notRunningAppender) Commit() error   { return nil }
+func (notRunningAppender) Rollback() error { return nil }
+
+// errorRecorder is a log.Logger which records the most recent error logged
+// at the warn or error level before passing the log line to the next
+// logger.
+type errorRecorder struct {
+	next log.Logger
+
+	mut      sync.Mutex
+	lastErr  error
+	lastTime time.Time
+}
+
+// Log implements log.Logger.
+func (r *errorRecorder) Log(keyvals ...interface{}) error {
+	var (
+		isProblem bool
+		err       error
+	)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		switch keyvals[i] {
+		case level.Key():
+			isProblem = keyvals[i+1] == level.ErrorValue() || keyvals[i+1] == level.WarnValue()
+		case "err":
+			if e, ok := keyvals[i+1].(error); ok {
+				err = e
+			}
+		}
+	}
+
+	if isProblem && err != nil {
+		r.mut.Lock()
+		r.lastErr, r.lastTime = err, time.Now()
+		r.mut.Unlock()
+	}
+	return r.next.Log(keyvals...)
+}
+
+// LastError returns the most recently recorded error and when it was
+// recorded.
+func (r *errorRecorder) LastError() (error, time.Time) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.lastErr, r.lastTime
 }
@@ -27,6 +27,9 @@ type Scrape struct {
 	configMut    sync.Mutex
 	sc           ScrapeConfig
 	reloadConfig chan struct{}
+
+	managerMut sync.RWMutex
+	manager    *scrape.Manager // Only set while running
 }
 
 // NewScrape creates a new Scrape. When running, collected metrics will be sent
@@ -61,9 +64,23 @@ func (s *Scrape) Run(ctx context.Context, updated func()) {
 	sm := scrape.NewManager(&scrape.Options{}, s.logger, s.app)
 	defer sm.Stop()
 
+	s.setManager(sm)
+	defer s.setManager(nil)
+
 	tsets := make(chan map[string][]*targetgroup.Group, 1)
 	go sm.Run(tsets)
 
+	// The scrape manager above starts empty, so apply any config and targets
+	// set by Configure before Run was called.
+	s.configMut.Lock()
+	if s.sc.Config != nil {
+		select {
+		case s.reloadConfig <- struct{}{}:
+		default:
+		}
+	}
+	s.configMut.Unlock()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -91,3 +108,27 @@ func (s *Scrape) Run(ctx context.Context, updated func()) {
 		}
 	}
 }
+
+func (s *Scrape) setManager(sm *scrape.Manager) {
+	s.managerMut.Lock()
+	defer s.managerMut.Unlock()
+	s.manager = sm
+}
+
+// Targets returns the current set of active and dropped targets. Both will
+// be empty if Scrape isn't running.
+func (s *Scrape) Targets() (active, dropped []*scrape.Target) {
+	s.managerMut.RLock()
+	defer s.managerMut.RUnlock()
+
+	if s.manager == nil {
+		return nil, nil
+	}
+	for _, targets := range s.manager.TargetsActive() {
+		active = append(active, targets...)
+	}
+	for _, targets := range s.manager.TargetsDropped() {
+		dropped = append(dropped, targets...)
+	}
+	return active, dropped
+}
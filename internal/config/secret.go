@@ -0,0 +1,83 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Secret is a string which holds sensitive data, such as a password or a
+// token. Secret fields are decoded and encoded like any other string, but are
+// redacted by RedactSecrets.
+type Secret string
+
+// Redacted is the value secrets are replaced with by RedactSecrets.
+const Redacted = "(redacted)"
+
+var secretType = reflect.TypeOf(Secret(""))
+
+// RedactSecrets returns a copy of val where the value of every Secret field
+// of v is replaced with Redacted. val must have been encoded from a value
+// with the same type as v, such as by EncodeCty.
+//
+// Null and empty secrets are kept as they are so it remains visible whether a
+// secret was set.
+func RedactSecrets(val cty.Value, v interface{}) cty.Value {
+	return redactSecrets(val, reflect.TypeOf(v))
+}
+
+func redactSecrets(val cty.Value, ty reflect.Type) cty.Value {
+	if val.IsNull() || !val.IsKnown() {
+		return val
+	}
+
+	if ty == secretType {
+		if val.Type() == cty.String && val.AsString() == "" {
+			return val
+		}
+		return cty.StringVal(Redacted)
+	}
+
+	switch ty.Kind() {
+	case reflect.Ptr:
+		return redactSecrets(val, ty.Elem())
+
+	case reflect.Struct:
+		if !val.Type().IsObjectType() {
+			return val
+		}
+		attrs := val.AsValueMap()
+		for i := 0; i < ty.NumField(); i++ {
+			field := ty.Field(i)
+			name := field.Tag.Get("cty")
+			if attr, ok := attrs[name]; ok {
+				attrs[name] = redactSecrets(attr, field.Type)
+			}
+		}
+		return cty.ObjectVal(attrs)
+
+	case reflect.Slice:
+		if !val.Type().IsListType() || val.LengthInt() == 0 {
+			return val
+		}
+		elems := make([]cty.Value, 0, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			elems = append(elems, redactSecrets(elem, ty.Elem()))
+		}
+		return cty.ListVal(elems)
+
+	case reflect.Map:
+		if !val.Type().IsMapType() || val.LengthInt() == 0 {
+			return val
+		}
+		elems := make(map[string]cty.Value, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			key, elem := it.Element()
+			elems[key.AsString()] = redactSecrets(elem, ty.Elem())
+		}
+		return cty.MapVal(elems)
+	}
+
+	return val
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	in := DiscoveryHTTP{
+		URL:         "http://localhost:8080/sd",
+		BearerToken: "token",
+		BasicAuth:   &BasicAuth{Username: "user", Password: "password"},
+	}
+	val, err := EncodeCty(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out DiscoveryHTTP
+	if err := DecodeCty(RedactSecrets(val, in), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.URL != in.URL {
+		t.Errorf("expected url %q to be kept, got %q", in.URL, out.URL)
+	}
+	if out.BearerToken != Redacted {
+		t.Errorf("expected bearer_token to be redacted, got %q", out.BearerToken)
+	}
+	if out.BasicAuth.Username != "user" {
+		t.Errorf("expected basic_auth username to be kept, got %q", out.BasicAuth.Username)
+	}
+	if out.BasicAuth.Password != Redacted {
+		t.Errorf("expected basic_auth password to be redacted, got %q", out.BasicAuth.Password)
+	}
+}
+
+func TestRedactSecrets_Unset(t *testing.T) {
+	in := DiscoveryConsul{Server: "localhost:8500"}
+	val, err := EncodeCty(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := RedactSecrets(val, in)
+	if token := res.GetAttr("token"); !token.RawEquals(cty.StringVal("")) {
+		t.Errorf("expected empty token to be kept, got %#v", token)
+	}
+	if basicAuth := res.GetAttr("basic_auth"); !basicAuth.IsNull() {
+		t.Errorf("expected null basic_auth to be kept, got %#v", basicAuth)
+	}
+}
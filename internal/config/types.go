@@ -26,21 +26,136 @@ type DiscoveryStatic struct {
 
 // DiscoveryChain configures chain Prometheus SD.
 type DiscoveryChain struct {
-	Input []TargetGroup `hcl:"input" cty:"input"`
+	Input      []TargetGroup     `hcl:"input" cty:"input"`
+	KeepLabels map[string]string `hcl:"keep_labels,optional" cty:"keep_labels"`
+	DropLabels map[string]string `hcl:"drop_labels,optional" cty:"drop_labels"`
+}
+
+// DiscoveryFile configures file-based Prometheus SD.
+type DiscoveryFile struct {
+	Files           []string `hcl:"files" cty:"files"`
+	RefreshInterval string   `hcl:"refresh_interval,optional" cty:"refresh_interval"`
+}
+
+// DiscoveryHTTP configures HTTP-based Prometheus SD.
+type DiscoveryHTTP struct {
+	URL             string `hcl:"url" cty:"url"`
+	RefreshInterval string `hcl:"refresh_interval,optional" cty:"refresh_interval"`
+
+	BearerToken     Secret     `hcl:"bearer_token,optional" cty:"bearer_token"`
+	BearerTokenFile string     `hcl:"bearer_token_file,optional" cty:"bearer_token_file"`
+	BasicAuth       *BasicAuth `hcl:"basic_auth,block" cty:"basic_auth"`
+	TLSConfig       *TLSConfig `hcl:"tls_config,block" cty:"tls_config"`
+}
+
+// DiscoveryDNS configures DNS-based Prometheus SD.
+type DiscoveryDNS struct {
+	Names           []string `hcl:"names" cty:"names"`
+	Type            string   `hcl:"type,optional" cty:"type"`
+	Port            int      `hcl:"port,optional" cty:"port"`
+	RefreshInterval string   `hcl:"refresh_interval,optional" cty:"refresh_interval"`
+}
+
+// DiscoveryKubernetes configures Kubernetes Prometheus SD.
+type DiscoveryKubernetes struct {
+	Role           string               `hcl:"role" cty:"role"`
+	APIServer      string               `hcl:"api_server,optional" cty:"api_server"`
+	KubeConfigFile string               `hcl:"kubeconfig_file,optional" cty:"kubeconfig_file"`
+	Namespaces     []string             `hcl:"namespaces,optional" cty:"namespaces"`
+	OwnNamespace   bool                 `hcl:"own_namespace,optional" cty:"own_namespace"`
+	Selectors      []KubernetesSelector `hcl:"selector,block" cty:"selector"`
+
+	BearerToken     Secret     `hcl:"bearer_token,optional" cty:"bearer_token"`
+	BearerTokenFile string     `hcl:"bearer_token_file,optional" cty:"bearer_token_file"`
+	BasicAuth       *BasicAuth `hcl:"basic_auth,block" cty:"basic_auth"`
+	TLSConfig       *TLSConfig `hcl:"tls_config,block" cty:"tls_config"`
+}
+
+// KubernetesSelector limits the Kubernetes resources of a role which are
+// discovered.
+type KubernetesSelector struct {
+	Role  string `hcl:"role" cty:"role"`
+	Label string `hcl:"label,optional" cty:"label"`
+	Field string `hcl:"field,optional" cty:"field"`
+}
+
+// DiscoveryConsul configures Consul Prometheus SD.
+type DiscoveryConsul struct {
+	Server          string            `hcl:"server,optional" cty:"server"`
+	Scheme          string            `hcl:"scheme,optional" cty:"scheme"`
+	Token           Secret            `hcl:"token,optional" cty:"token"`
+	Datacenter      string            `hcl:"datacenter,optional" cty:"datacenter"`
+	Namespace       string            `hcl:"namespace,optional" cty:"namespace"`
+	Services        []string          `hcl:"services,optional" cty:"services"`
+	Tags            []string          `hcl:"tags,optional" cty:"tags"`
+	TagSeparator    string            `hcl:"tag_separator,optional" cty:"tag_separator"`
+	NodeMeta        map[string]string `hcl:"node_meta,optional" cty:"node_meta"`
+	AllowStale      *bool             `hcl:"allow_stale,optional" cty:"allow_stale"`
+	RefreshInterval string            `hcl:"refresh_interval,optional" cty:"refresh_interval"`
+
+	BasicAuth *BasicAuth `hcl:"basic_auth,block" cty:"basic_auth"`
+	TLSConfig *TLSConfig `hcl:"tls_config,block" cty:"tls_config"`
+}
+
+// BasicAuth configures HTTP basic authentication credentials.
+type BasicAuth struct {
+	Username     string `hcl:"username" cty:"username"`
+	Password     Secret `hcl:"password,optional" cty:"password"`
+	PasswordFile string `hcl:"password_file,optional" cty:"password_file"`
+}
+
+// TLSConfig configures TLS for HTTP clients.
+type TLSConfig struct {
+	CAFile             string `hcl:"ca_file,optional" cty:"ca_file"`
+	CertFile           string `hcl:"cert_file,optional" cty:"cert_file"`
+	KeyFile            string `hcl:"key_file,optional" cty:"key_file"`
+	ServerName         string `hcl:"server_name,optional" cty:"server_name"`
+	InsecureSkipVerify bool   `hcl:"insecure_skip_verify,optional" cty:"insecure_skip_verify"`
 }
 
 // TargetGroup is a set of targets that share a common set of labels.
 type TargetGroup struct {
 	Targets []LabelSet `hcl:"targets" cty:"targets"`
 	Labels  LabelSet   `hcl:"labels,optional" cty:"labels"`
+	Source  string     `hcl:"source,optional" cty:"source"`
 }
 
 // LabelSet is a map of label names to values.
 type LabelSet map[string]string
 
+// Relabel configures relabeling a set of targets.
+type Relabel struct {
+	Input []TargetGroup   `hcl:"input" cty:"input"`
+	Rules []RelabelConfig `hcl:"rule,block" cty:"rule"`
+}
+
+// RelabelConfig is a single Prometheus relabeling rule. Unset fields use the
+// Prometheus defaults.
+type RelabelConfig struct {
+	SourceLabels []string `hcl:"source_labels,optional" cty:"source_labels"`
+	Separator    *string  `hcl:"separator,optional" cty:"separator"`
+	Regex        *string  `hcl:"regex,optional" cty:"regex"`
+	Modulus      uint64   `hcl:"modulus,optional" cty:"modulus"`
+	TargetLabel  string   `hcl:"target_label,optional" cty:"target_label"`
+	Replacement  *string  `hcl:"replacement,optional" cty:"replacement"`
+	Action       string   `hcl:"action,optional" cty:"action"`
+}
+
 // MetricsScrape configures scraping a set of metrics from targets.
 type MetricsScrape struct {
-	Targets []TargetGroup `hcl:"targets" cty:"targets"`
+	Targets   []TargetGroup `hcl:"targets" cty:"targets"`
+	ForwardTo []string      `hcl:"forward_to,optional" cty:"forward_to"`
+
+	JobName        string `hcl:"job_name,optional" cty:"job_name"`
+	ScrapeInterval string `hcl:"scrape_interval,optional" cty:"scrape_interval"`
+	ScrapeTimeout  string `hcl:"scrape_timeout,optional" cty:"scrape_timeout"`
+}
+
+// MetricsRelabel configures relabeling metrics before forwarding them to
+// receivers.
+type MetricsRelabel struct {
+	ForwardTo []string        `hcl:"forward_to" cty:"forward_to"`
+	Rules     []RelabelConfig `hcl:"rule,block" cty:"rule"`
 }
 
 // RemoteWrite configures where to send metrics to.
@@ -3,10 +3,19 @@ package dag
 import (
 	"bytes"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 // MarshalDOT marshals g into the DOT language defined by Graphviz.
 func MarshalDOT(g *Graph) []byte {
+	return MarshalDOTAttributes(g, nil)
+}
+
+// MarshalDOTAttributes is like MarshalDOT, but also writes the Graphviz
+// attributes returned by attrs for each vertex, such as its tooltip or color.
+// attrs may be nil or return nil for vertices without attributes.
+func MarshalDOTAttributes(g *Graph, attrs func(Node) map[string]string) []byte {
 	var buf bytes.Buffer
 
 	fmt.Fprintln(&buf, "digraph {")
@@ -14,7 +23,11 @@ func MarshalDOT(g *Graph) []byte {
 
 	fmt.Fprintf(&buf, "\n\t// Vertices:\n")
 	for _, v := range g.Nodes() {
-		fmt.Fprintf(&buf, "\t%q\n", v.Name())
+		var list string
+		if attrs != nil {
+			list = attributeList(attrs(v))
+		}
+		fmt.Fprintf(&buf, "\t%q%s\n", v.Name(), list)
 	}
 
 	fmt.Fprintf(&buf, "\n\t// Edges:\n")
@@ -25,3 +38,23 @@ func MarshalDOT(g *Graph) []byte {
 	fmt.Fprintln(&buf, "}")
 	return buf.Bytes()
 }
+
+// attributeList formats attrs as a DOT attribute list, sorted by name. An
+// empty string is returned if there are no attributes.
+func attributeList(attrs map[string]string) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, attrs[name]))
+	}
+	return " [" + strings.Join(pairs, ", ") + "]"
+}
@@ -0,0 +1,27 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarshalDOTAttributes(t *testing.T) {
+	g := buildGraph("a->b")
+
+	out := string(MarshalDOTAttributes(g, func(n Node) map[string]string {
+		if n.Name() != "a" {
+			return nil
+		}
+		return map[string]string{"tooltip": "first\nsecond", "color": "red"}
+	}))
+
+	for _, line := range []string{
+		`"a" [color="red", tooltip="first\nsecond"]`,
+		`"b"` + "\n",
+		`"a" -> "b"`,
+	} {
+		if !strings.Contains(out, "\t"+line) {
+			t.Errorf("expected output to contain %q, got:\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package dag
+
+// StronglyConnected returns the strongly connected components of g using
+// Tarjan's algorithm. Every Node in g belongs to exactly one strongly
+// connected component. g is left unmodified.
+//
+// Strongly connected components are returned in reverse topological order:
+// a component is always returned before any component which depends on it.
+func StronglyConnected(g *Graph) [][]Node {
+	var (
+		index   int
+		indices = make(map[Node]int)
+		lowLink = make(map[Node]int)
+
+		stack   []Node
+		onStack = make(nodeSet)
+
+		result [][]Node
+	)
+
+	var connect func(v Node)
+	connect = func(v Node) {
+		indices[v] = index
+		lowLink[v] = index
+		index++
+
+		stack = append(stack, v)
+		onStack.Add(v)
+
+		for w := range g.outEdges[v] {
+			if _, visited := indices[w]; !visited {
+				connect(w)
+				lowLink[v] = min(lowLink[v], lowLink[w])
+			} else if onStack.Has(w) {
+				lowLink[v] = min(lowLink[v], indices[w])
+			}
+		}
+
+		// v is the root of a strongly connected component if its low link is
+		// itself. Pop everything off the stack up to and including v to form
+		// the component.
+		if lowLink[v] == indices[v] {
+			var component []Node
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				delete(onStack, w)
+
+				component = append(component, w)
+				if w == v {
+					break
+				}
+			}
+			result = append(result, component)
+		}
+	}
+
+	for n := range g.nodes {
+		if _, visited := indices[n]; !visited {
+			connect(n)
+		}
+	}
+
+	return result
+}
+
+// Cycles returns the set of cycles in g. Each cycle is a strongly connected
+// component of g which either has more than one Node or has a single Node
+// with an edge to itself.
+//
+// Nodes which are part of a cycle are never visited by WalkTopological.
+func Cycles(g *Graph) [][]Node {
+	var cycles [][]Node
+	for _, component := range StronglyConnected(g) {
+		if len(component) > 1 || g.outEdges[component[0]].Has(component[0]) {
+			cycles = append(cycles, component)
+		}
+	}
+	return cycles
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
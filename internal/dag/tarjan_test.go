@@ -0,0 +1,140 @@
+package dag
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+type testNode string
+
+func (n testNode) Name() string { return string(n) }
+
+// buildGraph builds a graph from a set of edges, where "a->b" is an edge from
+// a to b. Edges without an arrow only add a node.
+func buildGraph(edges ...string) *Graph {
+	var g Graph
+	for _, e := range edges {
+		names := strings.SplitN(e, "->", 2)
+		for _, name := range names {
+			g.Add(testNode(name))
+		}
+		if len(names) == 2 {
+			g.AddEdge(Edge{From: testNode(names[0]), To: testNode(names[1])})
+		}
+	}
+	return &g
+}
+
+// componentNames converts components into sorted lists of names. The order of
+// the components themselves is kept.
+func componentNames(components [][]Node) []string {
+	res := make([]string, 0, len(components))
+	for _, c := range components {
+		names := make([]string, 0, len(c))
+		for _, n := range c {
+			names = append(names, n.Name())
+		}
+		sort.Strings(names)
+		res = append(res, strings.Join(names, ","))
+	}
+	return res
+}
+
+func TestStronglyConnected(t *testing.T) {
+	tt := []struct {
+		name   string
+		edges  []string
+		expect []string // Every component, in any order
+	}{
+		{
+			name:   "empty",
+			expect: []string{},
+		},
+		{
+			name:   "no edges",
+			edges:  []string{"a", "b"},
+			expect: []string{"a", "b"},
+		},
+		{
+			name:   "chain",
+			edges:  []string{"a->b", "b->c"},
+			expect: []string{"a", "b", "c"},
+		},
+		{
+			name:   "cycle",
+			edges:  []string{"a->b", "b->c", "c->a", "c->d"},
+			expect: []string{"a,b,c", "d"},
+		},
+		{
+			name:   "self edge",
+			edges:  []string{"a->a", "a->b"},
+			expect: []string{"a", "b"},
+		},
+		{
+			name:   "two cycles",
+			edges:  []string{"a->b", "b->a", "b->c", "c->d", "d->c"},
+			expect: []string{"a,b", "c,d"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := componentNames(StronglyConnected(buildGraph(tc.edges...)))
+			sort.Strings(actual)
+			if strings.Join(actual, " ") != strings.Join(tc.expect, " ") {
+				t.Fatalf("expected components %v, got %v", tc.expect, actual)
+			}
+		})
+	}
+}
+
+func TestStronglyConnected_Order(t *testing.T) {
+	g := buildGraph("a->b", "b->c", "c->b", "c->d", "e->a")
+
+	// Dependencies must be returned before their dependants.
+	expect := []string{"d", "b,c", "a", "e"}
+	actual := componentNames(StronglyConnected(g))
+	if strings.Join(actual, " ") != strings.Join(expect, " ") {
+		t.Fatalf("expected components in order %v, got %v", expect, actual)
+	}
+}
+
+func TestCycles(t *testing.T) {
+	tt := []struct {
+		name   string
+		edges  []string
+		expect []string
+	}{
+		{
+			name:   "acyclic",
+			edges:  []string{"a->b", "a->c", "b->c"},
+			expect: []string{},
+		},
+		{
+			name:   "cycle",
+			edges:  []string{"a->b", "b->c", "c->a", "c->d"},
+			expect: []string{"a,b,c"},
+		},
+		{
+			name:   "self edge",
+			edges:  []string{"a->a", "a->b"},
+			expect: []string{"a"},
+		},
+		{
+			name:   "two cycles",
+			edges:  []string{"a->b", "b->a", "b->c", "c->d", "d->c"},
+			expect: []string{"a,b", "c,d"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := componentNames(Cycles(buildGraph(tc.edges...)))
+			sort.Strings(actual)
+			if strings.Join(actual, " ") != strings.Join(tc.expect, " ") {
+				t.Fatalf("expected cycles %v, got %v", tc.expect, actual)
+			}
+		})
+	}
+}
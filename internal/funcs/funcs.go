@@ -0,0 +1,110 @@
+// Package funcs holds the table of functions which may be called from
+// expressions.
+//
+// The table is versioned: every function records the table version it was
+// introduced in, so the set of available functions can be pinned to an older
+// version. Version must be incremented whenever functions are added. Existing
+// functions must never be removed or change behavior.
+package funcs
+
+import (
+	ctyyaml "github.com/zclconf/go-cty-yaml"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// Version is the latest version of the function table.
+const Version = 3
+
+// entry is a function in the table.
+type entry struct {
+	Func  function.Function
+	Since int // Table version the function was introduced in
+}
+
+var table = map[string]entry{
+	// Strings
+	"chomp":         {Func: stdlib.ChompFunc, Since: 1},
+	"format":        {Func: stdlib.FormatFunc, Since: 1},
+	"formatlist":    {Func: stdlib.FormatListFunc, Since: 1},
+	"indent":        {Func: stdlib.IndentFunc, Since: 1},
+	"join":          {Func: stdlib.JoinFunc, Since: 1},
+	"lower":         {Func: stdlib.LowerFunc, Since: 1},
+	"regex":         {Func: stdlib.RegexFunc, Since: 1},
+	"regexall":      {Func: stdlib.RegexAllFunc, Since: 1},
+	"regex_replace": {Func: stdlib.RegexReplaceFunc, Since: 1},
+	"replace":       {Func: stdlib.ReplaceFunc, Since: 1},
+	"split":         {Func: stdlib.SplitFunc, Since: 1},
+	"strlen":        {Func: stdlib.StrlenFunc, Since: 1},
+	"substr":        {Func: stdlib.SubstrFunc, Since: 1},
+	"title":         {Func: stdlib.TitleFunc, Since: 1},
+	"trim":          {Func: stdlib.TrimFunc, Since: 1},
+	"trimprefix":    {Func: stdlib.TrimPrefixFunc, Since: 1},
+	"trimspace":     {Func: stdlib.TrimSpaceFunc, Since: 1},
+	"trimsuffix":    {Func: stdlib.TrimSuffixFunc, Since: 1},
+	"upper":         {Func: stdlib.UpperFunc, Since: 1},
+
+	// Collections
+	"chunklist":    {Func: stdlib.ChunklistFunc, Since: 1},
+	"coalesce":     {Func: stdlib.CoalesceFunc, Since: 1},
+	"coalescelist": {Func: stdlib.CoalesceListFunc, Since: 1},
+	"compact":      {Func: stdlib.CompactFunc, Since: 1},
+	"concat":       {Func: stdlib.ConcatFunc, Since: 1},
+	"contains":     {Func: stdlib.ContainsFunc, Since: 1},
+	"distinct":     {Func: stdlib.DistinctFunc, Since: 1},
+	"element":      {Func: stdlib.ElementFunc, Since: 1},
+	"flatten":      {Func: stdlib.FlattenFunc, Since: 1},
+	"index":        {Func: stdlib.IndexFunc, Since: 1},
+	"keys":         {Func: stdlib.KeysFunc, Since: 1},
+	"length":       {Func: stdlib.LengthFunc, Since: 1},
+	"lookup":       {Func: stdlib.LookupFunc, Since: 1},
+	"merge":        {Func: stdlib.MergeFunc, Since: 1},
+	"range":        {Func: stdlib.RangeFunc, Since: 1},
+	"reverse":      {Func: stdlib.ReverseListFunc, Since: 1},
+	"setunion":     {Func: stdlib.SetUnionFunc, Since: 1},
+	"slice":        {Func: stdlib.SliceFunc, Since: 1},
+	"sort":         {Func: stdlib.SortFunc, Since: 1},
+	"values":       {Func: stdlib.ValuesFunc, Since: 1},
+	"zipmap":       {Func: stdlib.ZipmapFunc, Since: 1},
+
+	// Targets
+	"add_labels":          {Func: AddLabelsFunc, Since: 3},
+	"filter_targets":      {Func: FilterTargetsFunc, Since: 3},
+	"shard_targets":       {Func: ShardTargetsFunc, Since: 3},
+	"targets_from_hosts":  {Func: TargetsFromHostsFunc, Since: 3},
+	"targets_with_labels": {Func: TargetsWithLabelsFunc, Since: 3},
+
+	// Numbers
+	"abs":      {Func: stdlib.AbsoluteFunc, Since: 1},
+	"ceil":     {Func: stdlib.CeilFunc, Since: 1},
+	"floor":    {Func: stdlib.FloorFunc, Since: 1},
+	"log":      {Func: stdlib.LogFunc, Since: 1},
+	"max":      {Func: stdlib.MaxFunc, Since: 1},
+	"min":      {Func: stdlib.MinFunc, Since: 1},
+	"parseint": {Func: stdlib.ParseIntFunc, Since: 1},
+	"pow":      {Func: stdlib.PowFunc, Since: 1},
+	"signum":   {Func: stdlib.SignumFunc, Since: 1},
+
+	// Encoding
+	"csvdecode":  {Func: stdlib.CSVDecodeFunc, Since: 1},
+	"jsondecode": {Func: stdlib.JSONDecodeFunc, Since: 1},
+	"jsonencode": {Func: stdlib.JSONEncodeFunc, Since: 1},
+	"yamldecode": {Func: ctyyaml.YAMLDecodeFunc, Since: 1},
+	"yamlencode": {Func: ctyyaml.YAMLEncodeFunc, Since: 1},
+
+	// Environment
+	"env": {Func: EnvFunc, Since: 2},
+}
+
+// Functions returns the functions available at the given version of the
+// table. A new map is returned on every call, so callers may add their own
+// functions to it.
+func Functions(version int) map[string]function.Function {
+	funcs := make(map[string]function.Function, len(table))
+	for name, e := range table {
+		if e.Since <= version {
+			funcs[name] = e.Func
+		}
+	}
+	return funcs
+}
@@ -0,0 +1,178 @@
+package funcs
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var (
+	str  = cty.StringVal
+	num  = cty.NumberIntVal
+	strs = func(vv ...string) []cty.Value {
+		res := make([]cty.Value, 0, len(vv))
+		for _, v := range vv {
+			res = append(res, cty.StringVal(v))
+		}
+		return res
+	}
+)
+
+// functionTests holds a test for every function in the table.
+var functionTests = []struct {
+	name   string // Name of the function being tested
+	expr   string
+	expect cty.Value
+}{
+	// Strings
+	{"chomp", `chomp("hello\n")`, str("hello")},
+	{"format", `format("%s-%d", "a", 1)`, str("a-1")},
+	{"formatlist", `formatlist("%s!", ["a", "b"])`, cty.ListVal(strs("a!", "b!"))},
+	{"indent", `indent(2, "a\nb")`, str("a\n  b")},
+	{"join", `join(",", ["a", "b"])`, str("a,b")},
+	{"lower", `lower("ABC")`, str("abc")},
+	{"regex", `regex("[a-z]+", "123abc")`, str("abc")},
+	{"regexall", `regexall("[a-z]", "a1b")`, cty.ListVal(strs("a", "b"))},
+	{"regex_replace", `regex_replace("a1b2", "[0-9]", "")`, str("ab")},
+	{"replace", `replace("a-b", "-", "+")`, str("a+b")},
+	{"split", `split(",", "a,b")`, cty.ListVal(strs("a", "b"))},
+	{"strlen", `strlen("abc")`, num(3)},
+	{"substr", `substr("hello", 1, 3)`, str("ell")},
+	{"title", `title("hello world")`, str("Hello World")},
+	{"trim", `trim("--a--", "-")`, str("a")},
+	{"trimprefix", `trimprefix("foobar", "foo")`, str("bar")},
+	{"trimspace", `trimspace("  a ")`, str("a")},
+	{"trimsuffix", `trimsuffix("foobar", "bar")`, str("foo")},
+	{"upper", `upper("abc")`, str("ABC")},
+
+	// Collections
+	{"chunklist", `chunklist(["a", "b", "c"], 2)`, cty.ListVal([]cty.Value{cty.ListVal(strs("a", "b")), cty.ListVal(strs("c"))})},
+	{"coalesce", `coalesce(null, "a")`, str("a")},
+	{"coalescelist", `coalescelist([], ["a"])`, cty.TupleVal(strs("a"))},
+	{"compact", `compact(["a", "", "b"])`, cty.ListVal(strs("a", "b"))},
+	{"concat", `concat(["a"], ["b"])`, cty.TupleVal(strs("a", "b"))},
+	{"contains", `contains(["a", "b"], "b")`, cty.True},
+	{"distinct", `distinct(["a", "a", "b"])`, cty.ListVal(strs("a", "b"))},
+	{"element", `element(["a", "b"], 3)`, str("b")},
+	{"flatten", `flatten([["a"], ["b"]])`, cty.TupleVal(strs("a", "b"))},
+	{"index", `index(["a", "b"], 1)`, str("b")},
+	{"keys", `keys({a = 1, b = 2})`, cty.TupleVal(strs("a", "b"))},
+	{"length", `length(["a", "b"])`, num(2)},
+	{"lookup", `lookup({a = "x"}, "b", "default")`, str("default")},
+	{"merge", `merge({a = 1}, {b = 2})`, cty.ObjectVal(map[string]cty.Value{"a": num(1), "b": num(2)})},
+	{"range", `range(3)`, cty.ListVal([]cty.Value{num(0), num(1), num(2)})},
+	{"reverse", `reverse(["a", "b"])`, cty.TupleVal(strs("b", "a"))},
+	{"setunion", `setunion(["a"], ["b"])`, cty.SetVal(strs("a", "b"))},
+	{"slice", `slice(["a", "b", "c"], 1, 2)`, cty.TupleVal(strs("b"))},
+	{"sort", `sort(["b", "a"])`, cty.ListVal(strs("a", "b"))},
+	{"values", `values({a = 1, b = 2})`, cty.TupleVal([]cty.Value{num(1), num(2)})},
+	{"zipmap", `zipmap(["a", "b"], [1, 2])`, cty.ObjectVal(map[string]cty.Value{"a": num(1), "b": num(2)})},
+
+	// Targets
+	{"add_labels", `add_labels([{targets = [{__address__ = "a:80"}]}], {env = "prod"})[0].targets[0].env`, str("prod")},
+	{"filter_targets", `length(filter_targets([{targets = [{__address__ = "a:80"}, {__address__ = "b:80"}]}], "b:.*", "__address__")[0].targets)`, num(1)},
+	{"shard_targets", `length(shard_targets([{targets = [{__address__ = "a:80"}]}], 1, 0))`, num(1)},
+	{"targets_from_hosts", `targets_from_hosts(["a"], 80)[0].targets[0].__address__`, str("a:80")},
+	{"targets_with_labels", `length(targets_with_labels([{targets = [{__address__ = "a:80", env = "prod"}]}], {env = "dev"}))`, num(0)},
+
+	// Numbers
+	{"abs", `abs(-1)`, num(1)},
+	{"ceil", `ceil(1.2)`, num(2)},
+	{"floor", `floor(1.8)`, num(1)},
+	{"log", `log(8, 2)`, num(3)},
+	{"max", `max(1, 3, 2)`, num(3)},
+	{"min", `min(2, 1, 3)`, num(1)},
+	{"parseint", `parseint("ff", 16)`, num(255)},
+	{"pow", `pow(2, 3)`, num(8)},
+	{"signum", `signum(-5)`, num(-1)},
+
+	// Encoding
+	{"csvdecode", `csvdecode("a,b\n1,2")`, cty.ListVal([]cty.Value{cty.ObjectVal(map[string]cty.Value{"a": str("1"), "b": str("2")})})},
+	{"jsondecode", `jsondecode("{\"a\":1}")`, cty.ObjectVal(map[string]cty.Value{"a": num(1)})},
+	{"jsonencode", `jsonencode({a = 1})`, str(`{"a":1}`)},
+	{"yamldecode", `yamldecode("a: 1")`, cty.ObjectVal(map[string]cty.Value{"a": num(1)})},
+	{"yamlencode", `yamlencode({a = 1})`, str("\"a\": 1\n")},
+
+	// Environment
+	{"env", `env("GRAGENT_FUNCS_TEST")`, str("value")},
+}
+
+func TestFunctions(t *testing.T) {
+	t.Setenv("GRAGENT_FUNCS_TEST", "value")
+
+	ectx := &hcl.EvalContext{Functions: Functions(Version)}
+	for _, tc := range functionTests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := evaluate(t, tc.expr, ectx)
+			if !actual.RawEquals(tc.expect) {
+				t.Fatalf("expected %#v, got %#v", tc.expect, actual)
+			}
+		})
+	}
+}
+
+// TestFunctions_Tested ensures that every function in the table has a test in
+// functionTests.
+func TestFunctions_Tested(t *testing.T) {
+	tested := make(map[string]bool, len(functionTests))
+	for _, tc := range functionTests {
+		tested[tc.name] = true
+	}
+	for name := range table {
+		if !tested[name] {
+			t.Errorf("function %s has no test in functionTests", name)
+		}
+	}
+}
+
+func TestFunctions_Version(t *testing.T) {
+	tt := []struct {
+		version int
+		present []string
+		absent  []string
+	}{
+		{version: 1, present: []string{"upper", "yamldecode"}, absent: []string{"env", "targets_from_hosts"}},
+		{version: 2, present: []string{"upper", "env"}, absent: []string{"targets_from_hosts", "add_labels"}},
+		{version: 3, present: []string{"upper", "env", "targets_from_hosts", "add_labels"}},
+	}
+
+	for _, tc := range tt {
+		funcs := Functions(tc.version)
+		for _, name := range tc.present {
+			if _, ok := funcs[name]; !ok {
+				t.Errorf("expected %s to be available at version %d", name, tc.version)
+			}
+		}
+		for _, name := range tc.absent {
+			if _, ok := funcs[name]; ok {
+				t.Errorf("expected %s to be unavailable at version %d", name, tc.version)
+			}
+		}
+	}
+
+	if latest := Functions(Version); len(latest) != len(table) {
+		t.Errorf("expected every function to be available at version %d, got %d of %d", Version, len(latest), len(table))
+	}
+	for name, e := range table {
+		if e.Since < 1 || e.Since > Version {
+			t.Errorf("function %s was introduced in version %d, which isn't between 1 and %d", name, e.Since, Version)
+		}
+	}
+}
+
+// evaluate parses and evaluates expr, failing the test if it's invalid.
+func evaluate(t *testing.T, expr string, ectx *hcl.EvalContext) cty.Value {
+	t.Helper()
+
+	e, diags := hclsyntax.ParseExpression([]byte(expr), "<test>", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("failed to parse %s: %s", expr, diags)
+	}
+	val, diags := e.Value(ectx)
+	if diags.HasErrors() {
+		t.Fatalf("failed to evaluate %s: %s", expr, diags)
+	}
+	return val
+}
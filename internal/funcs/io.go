@@ -0,0 +1,64 @@
+package funcs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// EnvFunc returns the value of an environment variable. An empty string is
+// returned if the variable isn't set.
+var EnvFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "name", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		return cty.StringVal(os.Getenv(args[0].AsString())), nil
+	},
+})
+
+// FileSince is the version of the function table the function returned by
+// MakeFileFunc was introduced in. Callers must only provide the function to
+// expressions when using at least this version of the table.
+const FileSince = 2
+
+// MakeFileFunc returns a function which reads the contents of a file as a
+// string. Relative paths are resolved against baseDir.
+//
+// Because files may change at any time, the function isn't part of the
+// table. onRead is invoked with the cleaned absolute path of every file
+// before it is read, even if reading it fails, so callers can track which
+// files an expression depends on.
+func MakeFileFunc(baseDir string, onRead func(path string)) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "path", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			path := args[0].AsString()
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(baseDir, path)
+			}
+			path, err := filepath.Abs(path)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			onRead(path)
+
+			bb, err := os.ReadFile(path)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			if !utf8.Valid(bb) {
+				return cty.NilVal, fmt.Errorf("contents of %s are not valid UTF-8", path)
+			}
+			return cty.StringVal(string(bb)), nil
+		},
+	})
+}
@@ -0,0 +1,259 @@
+package funcs
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/rfratto/gragent/internal/config"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+var (
+	// targetGroupsTy is the type of a list of config.TargetGroup, as returned
+	// by config.EncodeCty.
+	targetGroupsTy = cty.List(impliedType(config.TargetGroup{}))
+
+	// inputGroupsTy is the type target groups are converted into before being
+	// decoded. Unlike targetGroupsTy, labels and source may be omitted.
+	inputGroupsTy = cty.List(cty.ObjectWithOptionalAttrs(
+		targetGroupsTy.ElementType().AttributeTypes(),
+		[]string{"labels", "source"},
+	))
+)
+
+func impliedType(v interface{}) cty.Type {
+	ty, err := gocty.ImpliedType(v)
+	if err != nil {
+		panic(err)
+	}
+	return ty
+}
+
+// TargetsWithLabelsFunc returns the targets which have every label from a map
+// of label names to values. Labels of a group are treated as labels of each
+// of its targets.
+var TargetsWithLabelsFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "groups", Type: cty.DynamicPseudoType},
+		{Name: "labels", Type: cty.Map(cty.String)},
+	},
+	Type: function.StaticReturnType(targetGroupsTy),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		var labels map[string]string
+		if err := gocty.FromCtyValue(args[1], &labels); err != nil {
+			return cty.NilVal, function.NewArgError(1, err)
+		}
+
+		return mapTargets(args[0], func(lset config.LabelSet) config.LabelSet {
+			for name, value := range labels {
+				if v, ok := lset[name]; !ok || v != value {
+					return nil
+				}
+			}
+			return lset
+		})
+	},
+})
+
+// AddLabelsFunc sets labels from a map of label names to values on every
+// target, replacing existing labels with the same name.
+var AddLabelsFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "groups", Type: cty.DynamicPseudoType},
+		{Name: "labels", Type: cty.Map(cty.String)},
+	},
+	Type: function.StaticReturnType(targetGroupsTy),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		var labels map[string]string
+		if err := gocty.FromCtyValue(args[1], &labels); err != nil {
+			return cty.NilVal, function.NewArgError(1, err)
+		}
+
+		return mapTargets(args[0], func(lset config.LabelSet) config.LabelSet {
+			for name, value := range labels {
+				lset[name] = value
+			}
+			return lset
+		})
+	},
+})
+
+// FilterTargetsFunc returns the targets where the value of a label fully
+// matches a regular expression. Targets without the label are matched
+// against the empty string.
+var FilterTargetsFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "groups", Type: cty.DynamicPseudoType},
+		{Name: "regex", Type: cty.String},
+		{Name: "label", Type: cty.String},
+	},
+	Type: function.StaticReturnType(targetGroupsTy),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		re, err := relabel.NewRegexp(args[1].AsString())
+		if err != nil {
+			return cty.NilVal, function.NewArgError(1, err)
+		}
+		label := args[2].AsString()
+
+		return mapTargets(args[0], func(lset config.LabelSet) config.LabelSet {
+			if !re.MatchString(lset[label]) {
+				return nil
+			}
+			return lset
+		})
+	},
+})
+
+// TargetsFromHostsFunc converts a list of hosts into a single target group,
+// where every host is given the same port. Like the other target functions,
+// no group is returned if there are no hosts.
+var TargetsFromHostsFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "hosts", Type: cty.List(cty.String)},
+		{Name: "port", Type: cty.Number},
+	},
+	Type: function.StaticReturnType(targetGroupsTy),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		var (
+			hosts []string
+			port  int
+		)
+		if err := gocty.FromCtyValue(args[0], &hosts); err != nil {
+			return cty.NilVal, function.NewArgError(0, err)
+		}
+		if err := gocty.FromCtyValue(args[1], &port); err != nil {
+			return cty.NilVal, function.NewArgError(1, err)
+		} else if port <= 0 || port > 65535 {
+			return cty.NilVal, function.NewArgErrorf(1, "port must be between 1 and 65535")
+		}
+
+		if len(hosts) == 0 {
+			return config.EncodeCty([]config.TargetGroup{})
+		}
+
+		group := config.TargetGroup{
+			Targets: make([]config.LabelSet, 0, len(hosts)),
+			Labels:  config.LabelSet{},
+		}
+		for _, host := range hosts {
+			group.Targets = append(group.Targets, config.LabelSet{
+				model.AddressLabel: net.JoinHostPort(host, strconv.Itoa(port)),
+			})
+		}
+		return config.EncodeCty([]config.TargetGroup{group})
+	},
+})
+
+// ShardTargetsFunc splits targets into n shards by the hash of their labels
+// and returns the targets in shard i.
+var ShardTargetsFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "groups", Type: cty.DynamicPseudoType},
+		{Name: "n", Type: cty.Number},
+		{Name: "i", Type: cty.Number},
+	},
+	Type: function.StaticReturnType(targetGroupsTy),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		var n, i int
+		if err := gocty.FromCtyValue(args[1], &n); err != nil {
+			return cty.NilVal, function.NewArgError(1, err)
+		} else if n <= 0 {
+			return cty.NilVal, function.NewArgErrorf(1, "number of shards must be positive")
+		}
+		if err := gocty.FromCtyValue(args[2], &i); err != nil {
+			return cty.NilVal, function.NewArgError(2, err)
+		} else if i < 0 || i >= n {
+			return cty.NilVal, function.NewArgErrorf(2, "shard must be between 0 and %d", n-1)
+		}
+
+		return mapTargets(args[0], func(lset config.LabelSet) config.LabelSet {
+			ls := make(model.LabelSet, len(lset))
+			for name, value := range lset {
+				ls[model.LabelName(name)] = model.LabelValue(value)
+			}
+			if ls.Fingerprint()%model.Fingerprint(n) != model.Fingerprint(i) {
+				return nil
+			}
+			return lset
+		})
+	},
+})
+
+// mapTargets decodes val as a list of target groups and invokes fn for every
+// target. The lset passed to fn holds the labels of the group merged with the
+// labels of the target, and may be modified. Targets for which fn returns
+// nil are removed, and groups left without targets are removed entirely.
+//
+// The returned groups have their labels moved onto the targets.
+func mapTargets(val cty.Value, fn func(lset config.LabelSet) config.LabelSet) (cty.Value, error) {
+	groups, err := decodeTargetGroups(val)
+	if err != nil {
+		return cty.NilVal, function.NewArgError(0, err)
+	}
+
+	result := make([]config.TargetGroup, 0, len(groups))
+	for _, group := range groups {
+		out := config.TargetGroup{
+			Labels: config.LabelSet{},
+			Source: group.Source,
+		}
+		for _, target := range group.Targets {
+			lset := make(config.LabelSet, len(group.Labels)+len(target))
+			for name, value := range group.Labels {
+				lset[name] = value
+			}
+			for name, value := range target {
+				lset[name] = value
+			}
+
+			if lset = fn(lset); lset != nil {
+				out.Targets = append(out.Targets, lset)
+			}
+		}
+		if len(out.Targets) > 0 {
+			result = append(result, out)
+		}
+	}
+	return config.EncodeCty(result)
+}
+
+// decodeTargetGroups decodes val into a list of target groups. The labels
+// and source of groups may be omitted.
+func decodeTargetGroups(val cty.Value) ([]config.TargetGroup, error) {
+	if !val.IsWhollyKnown() {
+		return nil, fmt.Errorf("target groups must be known")
+	} else if val.IsNull() {
+		return nil, fmt.Errorf("target groups must not be null")
+	}
+
+	val, err := convert.Convert(val, inputGroupsTy)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]config.TargetGroup, 0, val.LengthInt())
+	for it := val.ElementIterator(); it.Next(); {
+		_, gv := it.Element()
+
+		var group config.TargetGroup
+		if err := gocty.FromCtyValue(gv.GetAttr("targets"), &group.Targets); err != nil {
+			return nil, err
+		}
+		if labels := gv.GetAttr("labels"); !labels.IsNull() {
+			if err := gocty.FromCtyValue(labels, &group.Labels); err != nil {
+				return nil, err
+			}
+		}
+		if source := gv.GetAttr("source"); !source.IsNull() {
+			group.Source = source.AsString()
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
@@ -0,0 +1,200 @@
+package funcs
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/rfratto/gragent/internal/config"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// testGroups is an expression for target groups used by the tests below.
+const testGroups = `[
+  {
+    labels  = {env = "prod"}
+    targets = [{__address__ = "a:80"}, {__address__ = "b:80", env = "dev"}]
+    source  = "first"
+  },
+  {
+    targets = [{__address__ = "c:80"}]
+  },
+]`
+
+func TestTargetFunctions(t *testing.T) {
+	tt := []struct {
+		name   string
+		expr   string
+		expect []config.TargetGroup
+	}{
+		{
+			name: "targets_with_labels uses group labels",
+			expr: `targets_with_labels(` + testGroups + `, {env = "prod"})`,
+			expect: []config.TargetGroup{{
+				Targets: []config.LabelSet{{"__address__": "a:80", "env": "prod"}},
+				Labels:  config.LabelSet{},
+				Source:  "first",
+			}},
+		},
+		{
+			name:   "targets_with_labels without matches",
+			expr:   `targets_with_labels(` + testGroups + `, {env = "staging"})`,
+			expect: []config.TargetGroup{},
+		},
+		{
+			name: "add_labels",
+			expr: `add_labels(` + testGroups + `, {env = "staging", team = "a"})`,
+			expect: []config.TargetGroup{
+				{
+					Targets: []config.LabelSet{
+						{"__address__": "a:80", "env": "staging", "team": "a"},
+						{"__address__": "b:80", "env": "staging", "team": "a"},
+					},
+					Labels: config.LabelSet{},
+					Source: "first",
+				},
+				{
+					Targets: []config.LabelSet{{"__address__": "c:80", "env": "staging", "team": "a"}},
+					Labels:  config.LabelSet{},
+				},
+			},
+		},
+		{
+			name: "filter_targets",
+			expr: `filter_targets(` + testGroups + `, "(a|c):80", "__address__")`,
+			expect: []config.TargetGroup{
+				{
+					Targets: []config.LabelSet{{"__address__": "a:80", "env": "prod"}},
+					Labels:  config.LabelSet{},
+					Source:  "first",
+				},
+				{
+					Targets: []config.LabelSet{{"__address__": "c:80"}},
+					Labels:  config.LabelSet{},
+				},
+			},
+		},
+		{
+			name: "filter_targets matches missing labels as empty",
+			expr: `filter_targets(` + testGroups + `, "", "env")`,
+			expect: []config.TargetGroup{{
+				Targets: []config.LabelSet{{"__address__": "c:80"}},
+				Labels:  config.LabelSet{},
+			}},
+		},
+		{
+			name:   "filter_targets without matches",
+			expr:   `filter_targets(` + testGroups + `, "d:80", "__address__")`,
+			expect: []config.TargetGroup{},
+		},
+		{
+			name: "targets_from_hosts",
+			expr: `targets_from_hosts(["a", "::1"], 9090)`,
+			expect: []config.TargetGroup{{
+				Targets: []config.LabelSet{
+					{"__address__": "a:9090"},
+					{"__address__": "[::1]:9090"},
+				},
+				Labels: config.LabelSet{},
+			}},
+		},
+		{
+			name:   "targets_from_hosts without hosts",
+			expr:   `targets_from_hosts([], 9090)`,
+			expect: []config.TargetGroup{},
+		},
+		{
+			name: "shard_targets with one shard",
+			expr: `shard_targets(` + testGroups + `, 1, 0)`,
+			expect: []config.TargetGroup{
+				{
+					Targets: []config.LabelSet{
+						{"__address__": "a:80", "env": "prod"},
+						{"__address__": "b:80", "env": "dev"},
+					},
+					Labels: config.LabelSet{},
+					Source: "first",
+				},
+				{
+					Targets: []config.LabelSet{{"__address__": "c:80"}},
+					Labels:  config.LabelSet{},
+				},
+			},
+		},
+	}
+
+	ectx := &hcl.EvalContext{Functions: Functions(Version)}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			expect, err := config.EncodeCty(tc.expect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual := evaluate(t, tc.expr, ectx)
+			if !actual.RawEquals(expect) {
+				t.Fatalf("expected %#v, got %#v", expect, actual)
+			}
+		})
+	}
+}
+
+// TestShardTargets ensures that every target is placed into exactly one
+// shard.
+func TestShardTargets(t *testing.T) {
+	const shards = 3
+
+	hosts := make([]string, 0, 20)
+	for i := 0; i < 20; i++ {
+		hosts = append(hosts, fmt.Sprintf("%q", fmt.Sprintf("host-%d", i)))
+	}
+	groups := `targets_from_hosts([` + strings.Join(hosts, ", ") + `], 80)`
+
+	ectx := &hcl.EvalContext{Functions: Functions(Version)}
+	seen := make(map[string]int)
+	for i := 0; i < shards; i++ {
+		val := evaluate(t, fmt.Sprintf("flatten(shard_targets(%s, %d, %d)[*].targets)", groups, shards, i), ectx)
+		for it := val.ElementIterator(); it.Next(); {
+			_, target := it.Element()
+			seen[target.Index(cty.StringVal("__address__")).AsString()]++
+		}
+	}
+
+	if len(seen) != len(hosts) {
+		t.Fatalf("expected %d targets across all shards, got %d", len(hosts), len(seen))
+	}
+	for addr, count := range seen {
+		if count != 1 {
+			t.Errorf("expected %s to be in exactly one shard, found in %d", addr, count)
+		}
+	}
+}
+
+func TestTargetFunctions_Errors(t *testing.T) {
+	tt := []struct {
+		expr   string
+		expect string
+	}{
+		{`targets_from_hosts(["a"], 0)`, "port must be between 1 and 65535"},
+		{`targets_from_hosts(["a"], 65536)`, "port must be between 1 and 65535"},
+		{`shard_targets(targets_from_hosts(["a"], 80), 0, 0)`, "number of shards must be positive"},
+		{`shard_targets(targets_from_hosts(["a"], 80), 2, 2)`, "shard must be between 0 and 1"},
+		{`filter_targets(targets_from_hosts(["a"], 80), "(", "__address__")`, "error parsing regexp"},
+		{`add_labels([{labels = {}}], {})`, `attribute "targets" is required`},
+	}
+
+	ectx := &hcl.EvalContext{Functions: Functions(Version)}
+	for _, tc := range tt {
+		t.Run(tc.expr, func(t *testing.T) {
+			expr, diags := hclsyntax.ParseExpression([]byte(tc.expr), "<test>", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			_, diags = expr.Value(ectx)
+			if !diags.HasErrors() || !strings.Contains(diags.Error(), tc.expect) {
+				t.Fatalf("expected error containing %q, got %v", tc.expect, diags)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package gragent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	hcljson "github.com/hashicorp/hcl/v2/json"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// argumentBlock is the block type which declares arguments, which is also the
+// name of the variable which holds their values. Arguments are referenced as
+// argument.<name>.
+const argumentBlock = "argument"
+
+// argumentSchema is the schema of argument blocks.
+var argumentSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "type"},
+		{Name: "default"},
+		{Name: "description"},
+	},
+}
+
+// argumentValue is a value for an argument set from outside of the config
+// file, either from a flag or from an arguments file.
+type argumentValue struct {
+	// Raw is the unparsed value from a flag. Raw is only used when Expr is nil.
+	Raw string
+
+	// Expr is the expression from an arguments file.
+	Expr hcl.Expression
+}
+
+// flagArgumentFilename is the filename used in the ranges of values set from
+// a flag, which have no source file.
+const flagArgumentFilename = "-config.arg"
+
+// Range returns the source range of v. Values set from a flag are given a
+// range in flagArgumentFilename.
+func (v argumentValue) Range() *hcl.Range {
+	if v.Expr == nil {
+		return &hcl.Range{Filename: flagArgumentFilename, Start: hcl.InitialPos, End: hcl.InitialPos}
+	}
+	return v.Expr.Range().Ptr()
+}
+
+// Value returns the value of v converted to ty. Raw values are used as
+// strings when ty is a primitive type or any type, and are otherwise parsed
+// as an expression.
+func (v argumentValue) Value(name string, ty cty.Type) (cty.Value, hcl.Diagnostics) {
+	expr := v.Expr
+	if expr == nil {
+		if ty.IsPrimitiveType() || ty == cty.DynamicPseudoType {
+			expr = &hclsyntax.LiteralValueExpr{Val: cty.StringVal(v.Raw)}
+		} else {
+			var diags hcl.Diagnostics
+			expr, diags = hclsyntax.ParseExpression([]byte(v.Raw), flagArgumentFilename, hcl.InitialPos)
+			if diags.HasErrors() {
+				for _, diag := range diags {
+					diag.Detail = fmt.Sprintf("Invalid value for argument %s: %s", name, diag.Detail)
+				}
+				return cty.NilVal, diags
+			}
+		}
+	}
+
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+	return convertArgument(name, val, ty, v.Range())
+}
+
+// convertArgument converts val into ty, returning a diagnostic against rng if
+// it can't be converted.
+func convertArgument(name string, val cty.Value, ty cty.Type, rng *hcl.Range) (cty.Value, hcl.Diagnostics) {
+	val, err := convert.Convert(val, ty)
+	if err != nil {
+		return cty.NilVal, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid value for argument",
+			Detail:   fmt.Sprintf("Invalid value for argument %s: %s.", name, err),
+			Subject:  rng,
+		}}
+	}
+	return val, nil
+}
+
+// readArgumentValues returns the values for arguments set through o. Values
+// are read from o.ArgumentsFile first, and then overridden by o.Arguments.
+// Arguments files ending in .json are read as JSON, and HCL otherwise.
+func readArgumentValues(o Options) (map[string]argumentValue, hcl.Diagnostics) {
+	values := make(map[string]argumentValue, len(o.Arguments))
+
+	if o.ArgumentsFile != "" {
+		bb, err := os.ReadFile(o.ArgumentsFile)
+		if err != nil {
+			return nil, hcl.Diagnostics{{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to read arguments file",
+				Detail:   fmt.Sprintf("Arguments file %s could not be read: %s.", o.ArgumentsFile, err),
+			}}
+		}
+
+		var (
+			file  *hcl.File
+			diags hcl.Diagnostics
+		)
+		if strings.EqualFold(filepath.Ext(o.ArgumentsFile), ".json") {
+			file, diags = hcljson.Parse(bb, o.ArgumentsFile)
+		} else {
+			file, diags = hclsyntax.ParseConfig(bb, o.ArgumentsFile, hcl.InitialPos)
+		}
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		attrs, diags := file.Body.JustAttributes()
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		for name, attr := range attrs {
+			values[name] = argumentValue{Expr: attr.Expr}
+		}
+	}
+
+	for name, raw := range o.Arguments {
+		values[name] = argumentValue{Raw: raw}
+	}
+	return values, nil
+}
+
+// decodeArguments decodes the argument blocks and returns the value of every
+// declared argument. Values are taken from values, falling back to the
+// default of the argument. ectx is used for evaluating defaults.
+//
+// Every argument without a default must have a value, and every value must
+// be for a declared argument.
+func decodeArguments(blocks []*hcl.Block, values map[string]argumentValue, ectx *hcl.EvalContext) (map[string]cty.Value, hcl.Diagnostics) {
+	var (
+		diags    hcl.Diagnostics
+		declared = make(map[string]*hcl.Block, len(blocks))
+		result   = make(map[string]cty.Value, len(blocks))
+	)
+
+	for _, block := range blocks {
+		name := block.Labels[0]
+		if _, exist := declared[name]; exist {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate argument",
+				Detail:   fmt.Sprintf("Argument %s is declared more than once", name),
+				Subject:  block.DefRange.Ptr(),
+			})
+			continue
+		}
+		declared[name] = block
+
+		val, adiags := decodeArgument(block, values, ectx)
+		diags = diags.Extend(adiags)
+		if !adiags.HasErrors() {
+			result[name] = val
+		}
+	}
+
+	// Sort undeclared names so diagnostics are reported in a consistent order.
+	var undeclared []string
+	for name := range values {
+		if _, ok := declared[name]; !ok {
+			undeclared = append(undeclared, name)
+		}
+	}
+	sort.Strings(undeclared)
+
+	for _, name := range undeclared {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Value for undeclared argument",
+			Detail:   fmt.Sprintf("A value was set for argument %s, but no argument block declares it.", name),
+			Subject:  values[name].Range(),
+		})
+	}
+
+	return result, diags
+}
+
+// decodeArgument decodes a single argument block and returns its value.
+func decodeArgument(block *hcl.Block, values map[string]argumentValue, ectx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	name := block.Labels[0]
+
+	content, diags := block.Body.Content(argumentSchema)
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+
+	ty := cty.DynamicPseudoType
+	if attr, ok := content.Attributes["type"]; ok {
+		ty, diags = typeexpr.TypeConstraint(attr.Expr)
+		if diags.HasErrors() {
+			return cty.NilVal, diags
+		}
+	}
+
+	if v, ok := values[name]; ok {
+		return v.Value(name, ty)
+	}
+
+	attr, ok := content.Attributes["default"]
+	if !ok {
+		return cty.NilVal, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Missing required argument",
+			Detail: fmt.Sprintf(
+				"Argument %s has no default value, so a value must be set with -config.arg or -config.args-file.",
+				name,
+			),
+			Subject: block.DefRange.Ptr(),
+		}}
+	}
+
+	val, diags := attr.Expr.Value(ectx)
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+	return convertArgument(name, val, ty, attr.Expr.Range().Ptr())
+}
@@ -0,0 +1,94 @@
+package gragent
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestLoad_Arguments(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "hosts"), "localhost:9090")
+	writeFile(t, filepath.Join(dir, "config.hcl"), `
+argument "hosts" {
+  type    = list(string)
+  default = [file("hosts")]
+}
+
+argument "env" {
+  type = string
+}
+
+discovery "static" "default" {
+  hosts  = argument.hosts
+  labels = {env = argument.env}
+}
+`)
+
+	tt := []struct {
+		name   string
+		args   map[string]string
+		expect string // Expected status or diagnostic substring
+	}{
+		{
+			name:   "default from file",
+			args:   map[string]string{"env": "prod"},
+			expect: `hosts = ["localhost:9090"]`,
+		},
+		{
+			name:   "list from flag",
+			args:   map[string]string{"env": "prod", "hosts": `["a:80", "b:80"]`},
+			expect: `hosts = ["a:80", "b:80"]`,
+		},
+		{
+			name:   "missing required argument",
+			expect: "config.hcl:7,1-15: Missing required argument",
+		},
+		{
+			name:   "invalid flag value",
+			args:   map[string]string{"env": "prod", "hosts": `"a:80"`},
+			expect: "-config.arg:1,1-1: Invalid value for argument; Invalid value for argument hosts: list of string required.",
+		},
+		{
+			name:   "unparseable flag value",
+			args:   map[string]string{"env": "prod", "hosts": `["a:80"`},
+			expect: "Invalid value for argument hosts:",
+		},
+		{
+			name:   "undeclared argument",
+			args:   map[string]string{"env": "prod", "port": "80"},
+			expect: "-config.arg:1,1-1: Value for undeclared argument",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewSystem(log.NewNopLogger(), Options{
+				ConfigFile: filepath.Join(dir, "config.hcl"),
+				Arguments:  tc.args,
+			})
+
+			var actual string
+			if err := s.Load(); err != nil {
+				var diags hcl.Diagnostics
+				if !errors.As(err, &diags) {
+					t.Fatalf("expected diagnostics, got %s", err)
+				}
+				actual = diags.Error()
+			} else {
+				var buf bytes.Buffer
+				_, _ = s.statusFile().WriteTo(&buf)
+				actual = buf.String()
+			}
+
+			if !strings.Contains(actual, tc.expect) {
+				t.Fatalf("expected %q in:\n%s", tc.expect, actual)
+			}
+		})
+	}
+}
@@ -2,40 +2,165 @@ package gragent
 
 import (
 	"context"
+	"fmt"
+	"time"
 
-	"github.com/hashicorp/hcl/v2"
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/storage"
 	"github.com/rfratto/gragent/internal/dag"
+	"github.com/rfratto/gragent/internal/promutils/discoveryext"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // The component interface is an extension of a dag.Node used for gragent.
 type component interface {
 	dag.Node
 
-	// Evaluate evaluates the HCL given the context provided. The component may
-	// use Evaluate to update internal state; i.e., updating the config if an
-	// underlying object.
+	// Update updates the component with its latest arguments, decoded from
+	// the component's block in the config file. args will always be the same
+	// type as the Args of the component's registration.
 	//
-	// Evaluate must return a struct that corresponds to the parsed HCL. The
-	// returned value must be consumably by go-cty; see config.EncodeCty for more
-	// information.
-	Evaluate(*hcl.EvalContext, hcl.Body) (interface{}, hcl.Diagnostics)
+	// Update is called every time the component's block is evaluated.
+	// Components may use Update to update internal state; i.e., updating the
+	// config of an underlying object.
+	Update(args interface{}) error
 
 	// CurrentState should return the latest state of the component that can be
 	// referenced by other component. If there is no state to return,
 	// CurrentState must return nil.
 	//
-	// CurrentState should return an instance of the same type every time it is
-	// called.
+	// CurrentState should return an instance of the State type from the
+	// component's registration every time it is called.
 	//
 	// The returned value must be consumable by go-cty; see config.EncodeCty for
 	// more information.
 	CurrentState() interface{}
 
-	// TODO(rfratto): CurrentStatus for debug-only state that can't be referenced
-	// by other objects.
-
 	// Run runs the component until ctx is canceled. Implementations must call
 	// onStateChange to signal that their state has changed. Callers may then
 	// call CurrentState to retrieve the state.
 	Run(ctx context.Context, onStateChange func())
 }
+
+// statusComponent is an optional extension of component for components which
+// expose debug-only status. Unlike state, status is never added to the eval
+// context and can't be referenced by other components.
+type statusComponent interface {
+	component
+
+	// CurrentStatus returns the latest debug information about the component.
+	// The returned value must be consumable by go-cty; see config.EncodeCty
+	// for more information.
+	CurrentStatus() interface{}
+}
+
+// componentOptions are the options passed to components when they are
+// created.
+type componentOptions struct {
+	Logger log.Logger
+
+	// DataPath is the directory where components may store data on disk.
+	// Components must store their data in a subdirectory named after their ID.
+	DataPath string
+
+	// ConfigDir is the directory of the config file. Relative paths in
+	// arguments are resolved against ConfigDir.
+	ConfigDir string
+
+	// Globals returns the most recently loaded root-level settings. Globals
+	// may only be called from Update.
+	Globals func() globalSettings
+
+	// Receiver returns the storage.Appendable for the component with the given
+	// ID. ok will be false if no such component exists or if it can't receive
+	// metrics. Receiver may only be called from Update.
+	Receiver func(id string) (app storage.Appendable, ok bool)
+
+	// DNSResolver is used by dns discovery components to look up records.
+	// net.DefaultResolver is used if nil.
+	DNSResolver discoveryext.Resolver
+}
+
+// resolveReceivers returns the storage.Appendable for each component ID in
+// forwardTo. resolveReceivers may only be called from Update.
+func resolveReceivers(o componentOptions, forwardTo []string) ([]storage.Appendable, error) {
+	receivers := make([]storage.Appendable, 0, len(forwardTo))
+	for _, id := range forwardTo {
+		app, ok := o.Receiver(id)
+		if !ok {
+			return nil, fmt.Errorf("forward_to: %q is not a component that can receive metrics", id)
+		}
+		receivers = append(receivers, app)
+	}
+	return receivers, nil
+}
+
+// globalsVariable is the name of the variable which exposes globalSettings to
+// expressions, such as global.scrape_interval.
+const globalsVariable = "global"
+
+// globalSettings are root-level settings used as defaults by components.
+type globalSettings struct {
+	ScrapeInterval model.Duration
+	ScrapeTimeout  model.Duration
+}
+
+// Value returns the cty representation of g to be exposed to expressions.
+func (g globalSettings) Value() cty.Value {
+	return cty.ObjectVal(map[string]cty.Value{
+		"scrape_interval": cty.StringVal(g.ScrapeInterval.String()),
+		"scrape_timeout":  cty.StringVal(g.ScrapeTimeout.String()),
+	})
+}
+
+// scrapeSettingError is an error for an invalid scrape setting.
+type scrapeSettingError struct {
+	Setting string // Name of the invalid setting, such as scrape_timeout
+	Err     error
+}
+
+func (e *scrapeSettingError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Setting, e.Err)
+}
+
+// resolveScrapeSettings parses the scrape interval and timeout, using the
+// values from defaults for settings which are empty. Like Prometheus, a
+// default timeout longer than the interval is clamped to the interval, but an
+// explicit timeout longer than the interval is rejected.
+//
+// Returned errors are always a *scrapeSettingError.
+func resolveScrapeSettings(interval, timeout string, defaults globalSettings) (globalSettings, error) {
+	res := defaults
+
+	parseDuration := func(name, in string, out *model.Duration) error {
+		if in == "" {
+			return nil
+		}
+		d, err := model.ParseDuration(in)
+		if err != nil {
+			return &scrapeSettingError{Setting: name, Err: err}
+		} else if d <= 0 {
+			return &scrapeSettingError{Setting: name, Err: fmt.Errorf("must be greater than zero")}
+		}
+		*out = d
+		return nil
+	}
+	if err := parseDuration("scrape_interval", interval, &res.ScrapeInterval); err != nil {
+		return defaults, err
+	}
+	if err := parseDuration("scrape_timeout", timeout, &res.ScrapeTimeout); err != nil {
+		return defaults, err
+	}
+
+	if time.Duration(res.ScrapeTimeout) > time.Duration(res.ScrapeInterval) {
+		if timeout != "" {
+			return defaults, &scrapeSettingError{
+				Setting: "scrape_timeout",
+				Err:     fmt.Errorf("%s must not be greater than scrape_interval %s", res.ScrapeTimeout, res.ScrapeInterval),
+			}
+		}
+		res.ScrapeTimeout = res.ScrapeInterval
+	}
+	return res, nil
+}
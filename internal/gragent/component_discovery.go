@@ -3,13 +3,19 @@ package gragent
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"reflect"
+	"strconv"
 
+	"github.com/go-kit/log"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
+	common_config "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/discovery"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/rfratto/gragent/internal/components"
 	"github.com/rfratto/gragent/internal/config"
 	"github.com/rfratto/gragent/internal/promutils/discoveryext"
 	"github.com/zclconf/go-cty/cty"
@@ -17,69 +23,78 @@ import (
 
 var targetGroupCapsuleTy = cty.Capsule("targetgroup", reflect.TypeOf(targetgroup.Group{}))
 
-type discoveryBlock struct {
-	Kind string `hcl:"kind,label"`
-	Name string `hcl:"name,label"`
-
-	Body   hcl.Body `hcl:",body"`
-	Remain hcl.Body `hcl:",remain"`
+func init() {
+	registerDiscovery("static", config.DiscoveryStatic{}, readStaticSD)
+	registerDiscovery("chain", config.DiscoveryChain{}, readChainSD)
 }
 
-type discoveryComponent struct {
-	kind, name string
+// discoveryConfigFunc converts the args of a discovery component into the
+// upstream discovery config. o holds the options the component was built
+// with.
+type discoveryConfigFunc func(o componentOptions, args interface{}) (discovery.Config, error)
+
+// registerDiscovery registers a discovery component of the given kind. Its
+// block is decoded into args and then converted using fn.
+func registerDiscovery(kind string, args interface{}, fn discoveryConfigFunc) {
+	register(registration{
+		Name:   "discovery." + kind,
+		Labels: 1,
+		Args:   args,
+		State:  discoveryState{},
+		Build: func(id string, o componentOptions) component {
+			return newDiscoveryComponent(id, o, fn)
+		},
+	})
 }
 
-func newDiscoveryComponent(kind, name string) *discoveryComponent {
-	return &discoveryComponent{
-		kind: kind,
-		name: name,
-	}
+// discoveryState is the state exported by discovery components.
+type discoveryState struct {
+	Targets []config.TargetGroup `hcl:"targets" cty:"targets"`
 }
 
-func (c *discoveryComponent) Name() string { return fmt.Sprintf("discovery.%s.%s", c.kind, c.name) }
+type discoveryComponent struct {
+	id       string
+	opts     componentOptions
+	configFn discoveryConfigFunc
+	disc     *components.Discovery
+}
 
-func (c *discoveryComponent) Evaluate(ectx *hcl.EvalContext, b hcl.Body) (interface{}, hcl.Diagnostics) {
-	var diags hcl.Diagnostics
+// discoveryStatus is the debug-only status of discovery components.
+type discoveryStatus struct {
+	// EmittedGroups is the total number of target groups emitted by the
+	// discoverer.
+	EmittedGroups int `hcl:"emitted_groups" cty:"emitted_groups"`
+}
 
-	switch c.kind {
-	case "static":
-		cfg, val, diags := readStaticSD(ectx, b)
-		if diags.HasErrors() {
-			return val, diags
-		}
-		_ = cfg
-		return val, diags
+var _ statusComponent = (*discoveryComponent)(nil)
 
-	case "chain":
-		cfg, val, diags := readChainSD(ectx, b)
-		if diags.HasErrors() {
-			return val, diags
-		}
-		_ = cfg
-		return val, diags
-
-	default:
-		diags = diags.Append(&hcl.Diagnostic{
-			Severity: hcl.DiagError,
-			Summary:  "Unknown discovery kind",
-			Detail:   fmt.Sprintf("Block %s has unknown discovery kind %q", c.Name(), c.kind),
-			Subject:  blockRange(b),
-		})
-		return cty.NilVal, diags
+func newDiscoveryComponent(id string, o componentOptions, fn discoveryConfigFunc) *discoveryComponent {
+	return &discoveryComponent{
+		id:       id,
+		opts:     o,
+		configFn: fn,
+		disc:     components.NewDiscovery(log.With(o.Logger, "id", id)),
 	}
 }
 
-func readStaticSD(ectx *hcl.EvalContext, b hcl.Body) (discovery.StaticConfig, interface{}, hcl.Diagnostics) {
-	var cfg config.DiscoveryStatic
+func (c *discoveryComponent) Name() string { return c.id }
 
-	diags := config.DecodeHCL(ectx, b, &cfg)
-	if diags.HasErrors() {
-		return nil, nil, diags
+func (c *discoveryComponent) Update(args interface{}) error {
+	cfg, err := c.configFn(c.opts, args)
+	if err != nil {
+		return err
 	}
 
+	c.disc.Configure(cfg)
+	return nil
+}
+
+func readStaticSD(o componentOptions, args interface{}) (discovery.Config, error) {
+	cfg := args.(config.DiscoveryStatic)
+
 	// Convert into the upstream type.
 	// TODO(rfratto): should this be a function somewhere else?
-	group := targetgroup.Group{Labels: make(model.LabelSet)}
+	group := targetgroup.Group{Labels: make(model.LabelSet), Source: "0"}
 	for _, target := range cfg.Hosts {
 		group.Targets = append(group.Targets, model.LabelSet{
 			model.AddressLabel: model.LabelValue(target),
@@ -89,24 +104,89 @@ func readStaticSD(ectx *hcl.EvalContext, b hcl.Body) (discovery.StaticConfig, in
 		group.Labels[model.LabelName(key)] = model.LabelValue(value)
 	}
 
-	return discovery.StaticConfig{&group}, cfg, diags
+	return discovery.StaticConfig{&group}, nil
 }
 
-func readChainSD(ectx *hcl.EvalContext, b hcl.Body) (*discoveryext.ChainConfig, interface{}, hcl.Diagnostics) {
-	var cfg config.DiscoveryChain
+func readChainSD(o componentOptions, args interface{}) (discovery.Config, error) {
+	cfg := args.(config.DiscoveryChain)
 
-	diags := config.DecodeHCL(ectx, b, &cfg)
-	if diags.HasErrors() {
-		return nil, nil, diags
+	keepLabels, err := labelMatchers(cfg.KeepLabels)
+	if err != nil {
+		return nil, fmt.Errorf("keep_labels: %w", err)
+	}
+	dropLabels, err := labelMatchers(cfg.DropLabels)
+	if err != nil {
+		return nil, fmt.Errorf("drop_labels: %w", err)
 	}
 
-	// Convert into the upstream type.
-	// TODO(rfratto): should this be a function somewhere else?
+	return &discoveryext.ChainConfig{
+		Input:      toTargetGroups(cfg.Input),
+		KeepLabels: keepLabels,
+		DropLabels: dropLabels,
+	}, nil
+}
+
+// labelMatchers compiles a map of label names to regular expressions. The
+// expressions are fully anchored.
+func labelMatchers(in map[string]string) (map[model.LabelName]relabel.Regexp, error) {
+	if len(in) == 0 {
+		return nil, nil
+	}
+
+	matchers := make(map[model.LabelName]relabel.Regexp, len(in))
+	for name, expr := range in {
+		if !model.LabelName(name).IsValid() {
+			return nil, fmt.Errorf("%q is not a valid label name", name)
+		}
+		re, err := relabel.NewRegexp(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex for %s: %w", name, err)
+		}
+		matchers[model.LabelName(name)] = re
+	}
+	return matchers, nil
+}
+
+// fromTargetGroups converts a set of upstream target groups into
+// config.TargetGroup.
+func fromTargetGroups(in []*targetgroup.Group) []config.TargetGroup {
+	finalGroups := make([]config.TargetGroup, 0, len(in))
+	for _, group := range in {
+		finalGroup := config.TargetGroup{
+			Targets: make([]config.LabelSet, 0, len(group.Targets)),
+			Labels:  make(config.LabelSet, len(group.Labels)),
+			Source:  group.Source,
+		}
+
+		for _, target := range group.Targets {
+			finalTarget := make(config.LabelSet, len(target))
+			for key, value := range target {
+				finalTarget[string(key)] = string(value)
+			}
+			finalGroup.Targets = append(finalGroup.Targets, finalTarget)
+		}
+
+		for key, value := range group.Labels {
+			finalGroup.Labels[string(key)] = string(value)
+		}
+
+		finalGroups = append(finalGroups, finalGroup)
+	}
+	return finalGroups
+}
+
+// toTargetGroups converts a set of config.TargetGroup into the upstream type.
+// Groups without a source are given one based on their index.
+func toTargetGroups(in []config.TargetGroup) []*targetgroup.Group {
 	var finalGroups []*targetgroup.Group
-	for _, group := range cfg.Input {
+	for i, group := range in {
 		finalGroup := targetgroup.Group{
 			Targets: make([]model.LabelSet, 0, len(group.Targets)),
 			Labels:  make(model.LabelSet, len(group.Labels)),
+			Source:  group.Source,
+		}
+		if finalGroup.Source == "" {
+			finalGroup.Source = strconv.Itoa(i)
 		}
 
 		for _, target := range group.Targets {
@@ -123,23 +203,77 @@ func readChainSD(ectx *hcl.EvalContext, b hcl.Body) (*discoveryext.ChainConfig,
 
 		finalGroups = append(finalGroups, &finalGroup)
 	}
-
-	return &discoveryext.ChainConfig{Input: finalGroups}, cfg, diags
+	return finalGroups
 }
 
 func (c *discoveryComponent) CurrentState() interface{} {
-	state := struct {
-		Targets []config.TargetGroup `hcl:"targets" cty:"targets"`
-	}{
-		Targets: make([]config.TargetGroup, 0),
-		// TODO(rfratto): populate state
+	state := discoveryState{
+		Targets: fromTargetGroups(c.disc.Targets()),
 	}
 
 	return &state
 }
 
+func (c *discoveryComponent) CurrentStatus() interface{} {
+	return &discoveryStatus{EmittedGroups: c.disc.EmittedGroups()}
+}
+
 func (c *discoveryComponent) Run(ctx context.Context, onStateChange func()) {
-	<-ctx.Done()
+	c.disc.Run(ctx, onStateChange)
+}
+
+// durationOrDefault parses in as a duration, returning def if in is empty.
+func durationOrDefault(in string, def model.Duration) (model.Duration, error) {
+	if in == "" {
+		return def, nil
+	}
+	return model.ParseDuration(in)
+}
+
+// parseHTTPURL parses in as the URL of an HTTP endpoint. The URL must be
+// absolute and use the http or https scheme.
+func parseHTTPURL(in string) (*url.URL, error) {
+	u, err := url.Parse(in)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("url scheme must be http or https, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("url must have a host")
+	}
+	return u, nil
+}
+
+// buildHTTPClientConfig converts HTTP client settings into the upstream type.
+// basicAuth and tlsConfig may be nil.
+func buildHTTPClientConfig(bearerToken, bearerTokenFile string, basicAuth *config.BasicAuth, tlsConfig *config.TLSConfig) (common_config.HTTPClientConfig, error) {
+	hc := common_config.DefaultHTTPClientConfig
+	hc.BearerToken = common_config.Secret(bearerToken)
+	hc.BearerTokenFile = bearerTokenFile
+
+	if basicAuth != nil {
+		if basicAuth.Password != "" && basicAuth.PasswordFile != "" {
+			return hc, fmt.Errorf("at most one of basic_auth password & password_file must be configured")
+		}
+		hc.BasicAuth = &common_config.BasicAuth{
+			Username:     basicAuth.Username,
+			Password:     common_config.Secret(basicAuth.Password),
+			PasswordFile: basicAuth.PasswordFile,
+		}
+	}
+	if tlsConfig != nil {
+		hc.TLSConfig = common_config.TLSConfig{
+			CAFile:             tlsConfig.CAFile,
+			CertFile:           tlsConfig.CertFile,
+			KeyFile:            tlsConfig.KeyFile,
+			ServerName:         tlsConfig.ServerName,
+			InsecureSkipVerify: tlsConfig.InsecureSkipVerify,
+		}
+	}
+
+	return hc, hc.Validate()
 }
 
 func blockRange(b hcl.Body) *hcl.Range {
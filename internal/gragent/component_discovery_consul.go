@@ -0,0 +1,58 @@
+package gragent
+
+import (
+	"fmt"
+	"strings"
+
+	common_config "github.com/prometheus/common/config"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/consul"
+	"github.com/rfratto/gragent/internal/config"
+)
+
+func init() {
+	registerDiscovery("consul", config.DiscoveryConsul{}, readConsulSD)
+}
+
+func readConsulSD(o componentOptions, args interface{}) (discovery.Config, error) {
+	cfg := args.(config.DiscoveryConsul)
+
+	// Convert into the upstream type. Settings which aren't provided keep the
+	// upstream defaults.
+	sdc := consul.DefaultSDConfig
+	if cfg.Server != "" {
+		sdc.Server = cfg.Server
+	}
+	if strings.TrimSpace(sdc.Server) == "" {
+		return nil, fmt.Errorf("server must not be empty")
+	}
+	if cfg.Scheme != "" {
+		sdc.Scheme = cfg.Scheme
+	}
+	if cfg.TagSeparator != "" {
+		sdc.TagSeparator = cfg.TagSeparator
+	}
+	if cfg.AllowStale != nil {
+		sdc.AllowStale = *cfg.AllowStale
+	}
+
+	sdc.Token = common_config.Secret(cfg.Token)
+	sdc.Datacenter = cfg.Datacenter
+	sdc.Namespace = cfg.Namespace
+	sdc.Services = cfg.Services
+	sdc.ServiceTags = cfg.Tags
+	sdc.NodeMeta = cfg.NodeMeta
+
+	interval, err := durationOrDefault(cfg.RefreshInterval, sdc.RefreshInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh_interval: %w", err)
+	}
+	sdc.RefreshInterval = interval
+
+	sdc.HTTPClientConfig, err = buildHTTPClientConfig("", "", cfg.BasicAuth, cfg.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sdc, nil
+}
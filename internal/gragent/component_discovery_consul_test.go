@@ -0,0 +1,196 @@
+package gragent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rfratto/gragent/internal/config"
+)
+
+// fakeConsulInstance is an instance of a service registered in the fake
+// Consul server.
+type fakeConsulInstance struct {
+	Address string
+	Port    int
+	Tags    []string
+}
+
+// fakeConsulCatalog holds the instances of every service by datacenter and
+// service name.
+var fakeConsulCatalog = map[string]map[string][]fakeConsulInstance{
+	"dc1": {
+		"web": {
+			{Address: "10.0.0.1", Port: 80, Tags: []string{"prod"}},
+			{Address: "10.0.0.2", Port: 80, Tags: []string{"canary"}},
+		},
+		"db": {
+			{Address: "10.0.0.3", Port: 5432, Tags: []string{"staging"}},
+		},
+	},
+	"dc2": {
+		"cache": {
+			{Address: "10.1.0.1", Port: 6379},
+		},
+	},
+}
+
+// newFakeConsulServer returns a server which implements the parts of the
+// Consul API used by the consul SD, serving fakeConsulCatalog. Requests
+// without a datacenter use dc1. Blocking queries return immediately.
+func newFakeConsulServer(t *testing.T) *httptest.Server {
+	writeJSON := func(w http.ResponseWriter, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Consul-Index", "1")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != "token" {
+			http.Error(w, "ACL not found", http.StatusForbidden)
+			return
+		}
+
+		dc := r.URL.Query().Get("dc")
+		if dc == "" {
+			dc = "dc1"
+		}
+		catalog := fakeConsulCatalog[dc]
+
+		switch {
+		case r.URL.Path == "/v1/agent/self":
+			writeJSON(w, map[string]interface{}{
+				"Config": map[string]interface{}{"Datacenter": "dc1"},
+			})
+
+		case r.URL.Path == "/v1/catalog/services":
+			services := make(map[string][]string, len(catalog))
+			for name, instances := range catalog {
+				services[name] = []string{}
+				for _, inst := range instances {
+					services[name] = append(services[name], inst.Tags...)
+				}
+			}
+			writeJSON(w, services)
+
+		case strings.HasPrefix(r.URL.Path, "/v1/health/service/"):
+			name := strings.TrimPrefix(r.URL.Path, "/v1/health/service/")
+			entries := []interface{}{}
+			for i, inst := range catalog[name] {
+				if !hasAllTags(inst.Tags, r.URL.Query()["tag"]) {
+					continue
+				}
+				entries = append(entries, map[string]interface{}{
+					"Node": map[string]interface{}{
+						"Node":       "node-" + inst.Address,
+						"Address":    inst.Address,
+						"Datacenter": dc,
+					},
+					"Service": map[string]interface{}{
+						"ID":      name + "-" + strconv.Itoa(i),
+						"Service": name,
+						"Tags":    inst.Tags,
+						"Port":    inst.Port,
+					},
+					"Checks": []interface{}{},
+				})
+			}
+			writeJSON(w, entries)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func hasAllTags(tags, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, tag := range tags {
+			if tag == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func TestConsulSD(t *testing.T) {
+	srv := newFakeConsulServer(t)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		name string
+		cfg  config.DiscoveryConsul
+
+		// Addresses of the discovered targets, keyed by "<datacenter>/<service>".
+		expect map[string][]string
+	}{
+		{
+			name: "all services",
+			cfg:  config.DiscoveryConsul{},
+			expect: map[string][]string{
+				"dc1/db":  {"10.0.0.3:5432"},
+				"dc1/web": {"10.0.0.1:80", "10.0.0.2:80"},
+			},
+		},
+		{
+			name: "services",
+			cfg:  config.DiscoveryConsul{Services: []string{"web"}},
+			expect: map[string][]string{
+				"dc1/web": {"10.0.0.1:80", "10.0.0.2:80"},
+			},
+		},
+		{
+			name: "tags",
+			cfg:  config.DiscoveryConsul{Tags: []string{"prod"}},
+			expect: map[string][]string{
+				"dc1/web": {"10.0.0.1:80"},
+			},
+		},
+		{
+			name: "datacenter",
+			cfg:  config.DiscoveryConsul{Datacenter: "dc2"},
+			expect: map[string][]string{
+				"dc2/cache": {"10.1.0.1:6379"},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := tc.cfg
+			cfg.Server = u.Host
+			cfg.Token = "token"
+
+			groups := discoverTargets(t, readConsulSD, cfg, hasGroups(len(tc.expect)))
+
+			actual := make(map[string][]string, len(groups))
+			for _, group := range groups {
+				key := group.Labels["__meta_consul_dc"] + "/" + group.Labels["__meta_consul_service"]
+				for _, target := range group.Targets {
+					actual[key] = append(actual[key], target["__address__"])
+				}
+				sort.Strings(actual[key])
+			}
+			if !reflect.DeepEqual(actual, tc.expect) {
+				t.Fatalf("expected targets %v, got %v", tc.expect, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package gragent
+
+import (
+	"fmt"
+
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/rfratto/gragent/internal/config"
+	"github.com/rfratto/gragent/internal/promutils/discoveryext"
+)
+
+func init() {
+	registerDiscovery("dns", config.DiscoveryDNS{}, readDNSSD)
+}
+
+func readDNSSD(o componentOptions, args interface{}) (discovery.Config, error) {
+	cfg := args.(config.DiscoveryDNS)
+
+	// Convert into our own type, which shares the upstream defaults.
+	sdc := discoveryext.DefaultDNSConfig
+	sdc.Names = cfg.Names
+	sdc.Port = cfg.Port
+	sdc.Resolver = o.DNSResolver
+	if cfg.Type != "" {
+		sdc.Type = cfg.Type
+	}
+
+	interval, err := durationOrDefault(cfg.RefreshInterval, sdc.RefreshInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh_interval: %w", err)
+	}
+	sdc.RefreshInterval = interval
+
+	if err := sdc.Validate(); err != nil {
+		return nil, err
+	}
+	return &sdc, nil
+}
@@ -0,0 +1,48 @@
+package gragent
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/rfratto/gragent/internal/config"
+)
+
+// staticResolver resolves every name to the same SRV records.
+type staticResolver []*net.SRV
+
+func (r staticResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	return name, r, nil
+}
+
+func (r staticResolver) LookupIP(_ context.Context, _, _ string) ([]net.IP, error) {
+	return nil, &net.DNSError{Err: "no such host", IsNotFound: true}
+}
+
+func TestDNSSD(t *testing.T) {
+	resolver := staticResolver{{Target: "node.example.com.", Port: 9100}}
+	readSD := func(o componentOptions, args interface{}) (discovery.Config, error) {
+		o.DNSResolver = resolver
+		return readDNSSD(o, args)
+	}
+
+	groups := discoverTargets(t, readSD, config.DiscoveryDNS{
+		Names: []string{"_node._tcp.example.com"},
+	}, hasGroups(1))
+
+	expect := []config.TargetGroup{{
+		Targets: []config.LabelSet{{
+			"__address__":                  "node.example.com:9100",
+			"__meta_dns_name":              "_node._tcp.example.com",
+			"__meta_dns_srv_record_target": "node.example.com.",
+			"__meta_dns_srv_record_port":   "9100",
+		}},
+		Labels: config.LabelSet{},
+		Source: "_node._tcp.example.com",
+	}}
+	if !reflect.DeepEqual(groups, expect) {
+		t.Fatalf("expected groups:\n%v\ngot:\n%v", expect, groups)
+	}
+}
@@ -0,0 +1,47 @@
+package gragent
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/file"
+	"github.com/rfratto/gragent/internal/config"
+)
+
+func init() {
+	registerDiscovery("file", config.DiscoveryFile{}, readFileSD)
+}
+
+// fileSDPattern matches the file patterns accepted by Prometheus file SD.
+// Only the final path segment may contain a wildcard.
+var fileSDPattern = regexp.MustCompile(`^[^*]*(\*[^/]*)?\.(json|yml|yaml|JSON|YML|YAML)$`)
+
+func readFileSD(o componentOptions, args interface{}) (discovery.Config, error) {
+	cfg := args.(config.DiscoveryFile)
+
+	// Convert into the upstream type. Files are watched for changes and
+	// re-read on every refresh interval in case a change was missed.
+	sdc := file.DefaultSDConfig
+	if len(cfg.Files) == 0 {
+		return nil, fmt.Errorf("files must not be empty")
+	}
+	for _, name := range cfg.Files {
+		if !fileSDPattern.MatchString(name) {
+			return nil, fmt.Errorf("path name %q is not valid for file discovery", name)
+		}
+	}
+	// Relative patterns are relative to the config file, the same as paths
+	// passed to file(). Copy the patterns first since SetDirectory modifies
+	// them in place.
+	sdc.Files = append([]string(nil), cfg.Files...)
+	sdc.SetDirectory(o.ConfigDir)
+
+	interval, err := durationOrDefault(cfg.RefreshInterval, sdc.RefreshInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh_interval: %w", err)
+	}
+	sdc.RefreshInterval = interval
+
+	return &sdc, nil
+}
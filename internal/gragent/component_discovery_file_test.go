@@ -0,0 +1,66 @@
+package gragent
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/rfratto/gragent/internal/config"
+)
+
+func TestFileSD(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "targets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "targets", "nodes.json")
+	writeFile(t, path, `[
+		{"targets": ["10.0.0.1:9100", "10.0.0.2:9100"], "labels": {"env": "prod"}}
+	]`)
+
+	// Relative patterns must be resolved against the config directory rather
+	// than the working directory.
+	readSD := func(o componentOptions, args interface{}) (discovery.Config, error) {
+		o.ConfigDir = dir
+		return readFileSD(o, args)
+	}
+
+	groups := discoverTargets(t, readSD, config.DiscoveryFile{
+		Files: []string{"targets/*.json"},
+	}, hasGroups(1))
+
+	expect := []config.TargetGroup{{
+		Targets: []config.LabelSet{
+			{"__address__": "10.0.0.1:9100"},
+			{"__address__": "10.0.0.2:9100"},
+		},
+		Labels: config.LabelSet{"env": "prod", "__meta_filepath": path},
+		Source: path + ":0",
+	}}
+	if !reflect.DeepEqual(groups, expect) {
+		t.Fatalf("expected groups:\n%v\ngot:\n%v", expect, groups)
+	}
+}
+
+func TestFileSD_Invalid(t *testing.T) {
+	tt := []struct {
+		name   string
+		files  []string
+		expect string
+	}{
+		{name: "empty", files: nil, expect: "files must not be empty"},
+		{name: "extension", files: []string{"targets.txt"}, expect: `path name "targets.txt" is not valid for file discovery`},
+		{name: "wildcard directory", files: []string{"*/targets.json"}, expect: `path name "*/targets.json" is not valid for file discovery`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readFileSD(componentOptions{}, config.DiscoveryFile{Files: tc.files})
+			if err == nil || err.Error() != tc.expect {
+				t.Fatalf("expected error %q, got %v", tc.expect, err)
+			}
+		})
+	}
+}
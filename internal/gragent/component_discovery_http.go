@@ -0,0 +1,37 @@
+package gragent
+
+import (
+	"fmt"
+
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/http"
+	"github.com/rfratto/gragent/internal/config"
+)
+
+func init() {
+	registerDiscovery("http", config.DiscoveryHTTP{}, readHTTPSD)
+}
+
+func readHTTPSD(o componentOptions, args interface{}) (discovery.Config, error) {
+	cfg := args.(config.DiscoveryHTTP)
+
+	sdc := http.DefaultSDConfig
+
+	if _, err := parseHTTPURL(cfg.URL); err != nil {
+		return nil, err
+	}
+	sdc.URL = cfg.URL
+
+	interval, err := durationOrDefault(cfg.RefreshInterval, sdc.RefreshInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh_interval: %w", err)
+	}
+	sdc.RefreshInterval = interval
+
+	sdc.HTTPClientConfig, err = buildHTTPClientConfig(string(cfg.BearerToken), cfg.BearerTokenFile, cfg.BasicAuth, cfg.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sdc, nil
+}
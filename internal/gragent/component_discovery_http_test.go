@@ -0,0 +1,71 @@
+package gragent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/rfratto/gragent/internal/config"
+)
+
+func TestHTTPSD(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "user" || pass != "password" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[
+			{"targets": ["10.0.0.1:9100", "10.0.0.2:9100"], "labels": {"env": "prod"}},
+			{"targets": ["10.0.0.3:9100"]}
+		]`))
+	}))
+	defer srv.Close()
+
+	groups := discoverTargets(t, readHTTPSD, config.DiscoveryHTTP{
+		URL:       srv.URL,
+		BasicAuth: &config.BasicAuth{Username: "user", Password: "password"},
+	}, hasGroups(2))
+
+	expect := []config.TargetGroup{
+		{
+			Targets: []config.LabelSet{
+				{"__address__": "10.0.0.1:9100"},
+				{"__address__": "10.0.0.2:9100"},
+			},
+			Labels: config.LabelSet{"env": "prod", "__meta_url": srv.URL},
+			Source: srv.URL + ":0",
+		},
+		{
+			Targets: []config.LabelSet{
+				{"__address__": "10.0.0.3:9100"},
+			},
+			Labels: config.LabelSet{"__meta_url": srv.URL},
+			Source: srv.URL + ":1",
+		},
+	}
+	if !reflect.DeepEqual(groups, expect) {
+		t.Fatalf("expected groups:\n%v\ngot:\n%v", expect, groups)
+	}
+}
+
+func TestHTTPSD_InvalidURL(t *testing.T) {
+	tt := []struct {
+		url    string
+		expect string
+	}{
+		{url: "ftp://localhost/sd", expect: `url scheme must be http or https, got "ftp"`},
+		{url: "http:///sd", expect: "url must have a host"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.url, func(t *testing.T) {
+			_, err := readHTTPSD(componentOptions{}, config.DiscoveryHTTP{URL: tc.url})
+			if err == nil || err.Error() != tc.expect {
+				t.Fatalf("expected error %q, got %v", tc.expect, err)
+			}
+		})
+	}
+}
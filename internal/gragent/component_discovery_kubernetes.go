@@ -0,0 +1,118 @@
+package gragent
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	common_config "github.com/prometheus/common/config"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/kubernetes"
+	"github.com/rfratto/gragent/internal/config"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func init() {
+	registerDiscovery("kubernetes", config.DiscoveryKubernetes{}, readKubernetesSD)
+}
+
+// kubernetesSelectorRoles are the selector roles allowed for each Kubernetes
+// SD role.
+var kubernetesSelectorRoles = map[kubernetes.Role][]kubernetes.Role{
+	kubernetes.RolePod:           {kubernetes.RolePod},
+	kubernetes.RoleService:       {kubernetes.RoleService},
+	kubernetes.RoleEndpointSlice: {kubernetes.RolePod, kubernetes.RoleService, kubernetes.RoleEndpointSlice},
+	kubernetes.RoleEndpoint:      {kubernetes.RolePod, kubernetes.RoleService, kubernetes.RoleEndpoint},
+	kubernetes.RoleNode:          {kubernetes.RoleNode},
+	kubernetes.RoleIngress:       {kubernetes.RoleIngress},
+}
+
+func readKubernetesSD(o componentOptions, args interface{}) (discovery.Config, error) {
+	cfg := args.(config.DiscoveryKubernetes)
+
+	// Convert into the upstream type. The checks below are the ones
+	// kubernetes.SDConfig runs when unmarshaling YAML, which is skipped here.
+	sdc := kubernetes.DefaultSDConfig
+
+	sdc.Role = kubernetes.Role(cfg.Role)
+	allowedSelectors, ok := kubernetesSelectorRoles[sdc.Role]
+	if !ok {
+		return nil, fmt.Errorf("invalid role %q, expecting one of: pod, service, endpoints, endpointslice, node or ingress", cfg.Role)
+	}
+
+	if cfg.APIServer != "" {
+		u, err := url.Parse(cfg.APIServer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid api_server: %w", err)
+		}
+		sdc.APIServer = common_config.URL{URL: u}
+	}
+	sdc.KubeConfig = cfg.KubeConfigFile
+	sdc.NamespaceDiscovery = kubernetes.NamespaceDiscovery{
+		IncludeOwnNamespace: cfg.OwnNamespace,
+		Names:               cfg.Namespaces,
+	}
+
+	hc, err := buildHTTPClientConfig(string(cfg.BearerToken), cfg.BearerTokenFile, cfg.BasicAuth, cfg.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	sdc.HTTPClientConfig = hc
+	customHTTP := !reflect.DeepEqual(hc, common_config.DefaultHTTPClientConfig)
+
+	switch {
+	case cfg.APIServer != "" && cfg.KubeConfigFile != "":
+		return nil, fmt.Errorf("cannot use kubeconfig_file and api_server simultaneously")
+	case cfg.KubeConfigFile != "" && customHTTP:
+		return nil, fmt.Errorf("cannot use a custom HTTP client configuration together with kubeconfig_file")
+	case cfg.APIServer == "" && customHTTP:
+		return nil, fmt.Errorf("to use custom HTTP client configuration please provide api_server explicitly")
+	case cfg.APIServer != "" && cfg.OwnNamespace:
+		return nil, fmt.Errorf("cannot use api_server and own_namespace simultaneously")
+	case cfg.KubeConfigFile != "" && cfg.OwnNamespace:
+		return nil, fmt.Errorf("cannot use kubeconfig_file and own_namespace simultaneously")
+	}
+
+	foundRoles := make(map[kubernetes.Role]struct{}, len(cfg.Selectors))
+	for _, selector := range cfg.Selectors {
+		role := kubernetes.Role(selector.Role)
+		if _, found := foundRoles[role]; found {
+			return nil, fmt.Errorf("duplicated selector role %q", role)
+		}
+		foundRoles[role] = struct{}{}
+
+		if !containsRole(allowedSelectors, role) {
+			names := make([]string, 0, len(allowedSelectors))
+			for _, allowed := range allowedSelectors {
+				names = append(names, string(allowed))
+			}
+			return nil, fmt.Errorf("%s role supports only %s selectors", sdc.Role, strings.Join(names, ", "))
+		}
+
+		if _, err := fields.ParseSelector(selector.Field); err != nil {
+			return nil, fmt.Errorf("invalid field selector %q: %w", selector.Field, err)
+		}
+		if _, err := labels.Parse(selector.Label); err != nil {
+			return nil, fmt.Errorf("invalid label selector %q: %w", selector.Label, err)
+		}
+
+		sdc.Selectors = append(sdc.Selectors, kubernetes.SelectorConfig{
+			Role:  role,
+			Label: selector.Label,
+			Field: selector.Field,
+		})
+	}
+
+	return &sdc, nil
+}
+
+func containsRole(roles []kubernetes.Role, role kubernetes.Role) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package gragent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rfratto/gragent/internal/config"
+)
+
+// fakeNodeList is a list of nodes returned by the fake Kubernetes API server.
+const fakeNodeList = `{
+	"kind": "NodeList",
+	"apiVersion": "v1",
+	"metadata": {"resourceVersion": "1"},
+	"items": [{
+		"metadata": {"name": "node-a", "labels": {"zone": "a"}},
+		"status": {
+			"addresses": [{"type": "InternalIP", "address": "10.0.0.1"}],
+			"daemonEndpoints": {"kubeletEndpoint": {"Port": 10250}}
+		}
+	}]
+}`
+
+// newFakeAPIServer returns a server which lists nodes from fakeNodeList and
+// holds watches open without sending any events.
+func newFakeAPIServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/nodes" {
+			http.NotFound(w, r)
+			return
+		}
+		if user, pass, _ := r.BasicAuth(); user != "user" || pass != "password" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		_, _ = w.Write([]byte(fakeNodeList))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestKubernetesSD(t *testing.T) {
+	srv := newFakeAPIServer(t)
+
+	groups := discoverTargets(t, readKubernetesSD, config.DiscoveryKubernetes{
+		Role:      "node",
+		APIServer: srv.URL,
+		BasicAuth: &config.BasicAuth{Username: "user", Password: "password"},
+	}, hasGroups(1))
+
+	group := groups[0]
+	if group.Source != "node/node-a" {
+		t.Errorf("expected source node/node-a, got %q", group.Source)
+	}
+	if len(group.Targets) != 1 {
+		t.Fatalf("expected 1 target, got %v", group.Targets)
+	}
+	if addr := group.Targets[0]["__address__"]; addr != "10.0.0.1:10250" {
+		t.Errorf("expected address 10.0.0.1:10250, got %q", addr)
+	}
+	for name, expect := range map[string]string{
+		"__meta_kubernetes_node_name":       "node-a",
+		"__meta_kubernetes_node_label_zone": "a",
+	} {
+		if actual := group.Labels[name]; actual != expect {
+			t.Errorf("expected label %s=%q, got %q", name, expect, actual)
+		}
+	}
+}
+
+func TestKubernetesSD_Invalid(t *testing.T) {
+	tt := []struct {
+		name   string
+		cfg    config.DiscoveryKubernetes
+		expect string
+	}{
+		{
+			name:   "unknown role",
+			cfg:    config.DiscoveryKubernetes{Role: "deployment"},
+			expect: `invalid role "deployment", expecting one of: pod, service, endpoints, endpointslice, node or ingress`,
+		},
+		{
+			name:   "custom HTTP client without api_server",
+			cfg:    config.DiscoveryKubernetes{Role: "node", BearerToken: "token"},
+			expect: "to use custom HTTP client configuration please provide api_server explicitly",
+		},
+		{
+			name: "bearer_token with basic_auth",
+			cfg: config.DiscoveryKubernetes{
+				Role:        "node",
+				APIServer:   "http://localhost:6443",
+				BearerToken: "token",
+				BasicAuth:   &config.BasicAuth{Username: "user", Password: "password"},
+			},
+			expect: "at most one of basic_auth, oauth2, bearer_token & bearer_token_file must be configured",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readKubernetesSD(componentOptions{}, tc.cfg)
+			if err == nil || err.Error() != tc.expect {
+				t.Fatalf("expected error %q, got %v", tc.expect, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package gragent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/rfratto/gragent/internal/config"
+)
+
+// discoverTargets runs a discovery component converting args with fn until
+// the targets it discovered satisfy done. The test fails if done isn't
+// satisfied within a few seconds.
+func discoverTargets(t *testing.T, fn discoveryConfigFunc, args interface{}, done func([]config.TargetGroup) bool) []config.TargetGroup {
+	t.Helper()
+
+	c := newDiscoveryComponent("discovery.test.default", componentOptions{Logger: log.NewNopLogger()}, fn)
+	if err := c.Update(args); err != nil {
+		t.Fatalf("unexpected error from Update: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var (
+		changed = make(chan struct{}, 1)
+		exited  = make(chan struct{})
+	)
+	go func() {
+		defer close(exited)
+		c.Run(ctx, func() {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+	}()
+	defer func() {
+		cancel()
+		<-exited
+	}()
+
+	for {
+		targets := c.CurrentState().(*discoveryState).Targets
+		if done(targets) {
+			return targets
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for targets, last discovered %v", targets)
+		case <-changed:
+		}
+	}
+}
+
+// hasGroups returns a func for discoverTargets which is satisfied once n
+// target groups have been discovered.
+func hasGroups(n int) func([]config.TargetGroup) bool {
+	return func(groups []config.TargetGroup) bool { return len(groups) >= n }
+}
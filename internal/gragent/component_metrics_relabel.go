@@ -0,0 +1,87 @@
+package gragent
+
+import (
+	"context"
+
+	"github.com/prometheus/prometheus/storage"
+	"github.com/rfratto/gragent/internal/components"
+	"github.com/rfratto/gragent/internal/config"
+)
+
+func init() {
+	register(registration{
+		Name:   "metrics_relabel",
+		Labels: 1,
+		Args:   config.MetricsRelabel{},
+		State:  metricsRelabelState{},
+		Build: func(id string, o componentOptions) component {
+			return newMetricsRelabelComponent(id, o)
+		},
+	})
+}
+
+// metricsRelabelState is the state exported by metrics_relabel components.
+type metricsRelabelState struct {
+	// Receiver is the ID of the component. Metrics sent to it through
+	// forward_to are relabeled before being passed to its own forward_to.
+	Receiver string `hcl:"receiver" cty:"receiver"`
+}
+
+// metricsRelabelComponent relabels metrics sent to it before forwarding them
+// to other receivers.
+type metricsRelabelComponent struct {
+	id   string
+	opts componentOptions
+
+	fanout  *components.Fanout
+	relabel *components.MetricsRelabel
+}
+
+var _ storage.Appendable = (*metricsRelabelComponent)(nil)
+
+func newMetricsRelabelComponent(id string, o componentOptions) *metricsRelabelComponent {
+	fanout := &components.Fanout{}
+
+	return &metricsRelabelComponent{
+		id:   id,
+		opts: o,
+
+		fanout:  fanout,
+		relabel: components.NewMetricsRelabel(fanout),
+	}
+}
+
+func (c *metricsRelabelComponent) Name() string { return c.id }
+
+func (c *metricsRelabelComponent) Update(args interface{}) error {
+	cfg := args.(config.MetricsRelabel)
+
+	rcs, err := buildRelabelConfigs(cfg.Rules)
+	if err != nil {
+		return err
+	}
+	receivers, err := resolveReceivers(c.opts, cfg.ForwardTo)
+	if err != nil {
+		return err
+	}
+
+	c.fanout.UpdateChildren(receivers)
+	c.relabel.UpdateRules(rcs)
+	return nil
+}
+
+func (c *metricsRelabelComponent) CurrentState() interface{} {
+	state := metricsRelabelState{Receiver: c.id}
+
+	return &state
+}
+
+// Appender implements storage.Appendable. Appended samples are relabeled and
+// then forwarded.
+func (c *metricsRelabelComponent) Appender(ctx context.Context) storage.Appender {
+	return c.relabel.Appender(ctx)
+}
+
+func (c *metricsRelabelComponent) Run(ctx context.Context, onStateChange func()) {
+	<-ctx.Done()
+}
@@ -0,0 +1,110 @@
+package gragent
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/rfratto/gragent/internal/config"
+)
+
+// recordingAppendable records the series of committed samples.
+type recordingAppendable struct {
+	mut    sync.Mutex
+	series []labels.Labels
+}
+
+func (r *recordingAppendable) Appender(context.Context) storage.Appender {
+	return &recordingAppender{parent: r}
+}
+
+func (r *recordingAppendable) Series() []labels.Labels {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.series
+}
+
+type recordingAppender struct {
+	parent  *recordingAppendable
+	pending []labels.Labels
+}
+
+func (a *recordingAppender) Append(_ storage.SeriesRef, l labels.Labels, _ int64, _ float64) (storage.SeriesRef, error) {
+	a.pending = append(a.pending, l)
+	return 0, nil
+}
+
+func (a *recordingAppender) AppendExemplar(_ storage.SeriesRef, l labels.Labels, _ exemplar.Exemplar) (storage.SeriesRef, error) {
+	return 0, nil
+}
+
+func (a *recordingAppender) Commit() error {
+	a.parent.mut.Lock()
+	defer a.parent.mut.Unlock()
+	a.parent.series = append(a.parent.series, a.pending...)
+	a.pending = nil
+	return nil
+}
+
+func (a *recordingAppender) Rollback() error {
+	a.pending = nil
+	return nil
+}
+
+func TestMetricsRelabelComponent(t *testing.T) {
+	next := &recordingAppendable{}
+	c := newMetricsRelabelComponent("metrics_relabel.test", componentOptions{
+		Logger: log.NewNopLogger(),
+		Receiver: func(id string) (storage.Appendable, bool) {
+			return next, id == "remote_write.default"
+		},
+	})
+
+	err := c.Update(config.MetricsRelabel{
+		ForwardTo: []string{"remote_write.default"},
+		Rules: []config.RelabelConfig{
+			{SourceLabels: []string{"__name__"}, Regex: stringPtr("go_.*"), Action: "drop"},
+			{SourceLabels: []string{"instance"}, Regex: stringPtr("(.*):.*"), TargetLabel: "host"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error from Update: %s", err)
+	}
+
+	app := c.Appender(context.Background())
+	for _, l := range []labels.Labels{
+		labels.FromStrings("__name__", "up", "instance", "node:9100"),
+		labels.FromStrings("__name__", "go_goroutines", "instance", "node:9100"),
+	} {
+		if _, err := app.Append(0, l, 0, 1); err != nil {
+			t.Fatalf("unexpected error from Append: %s", err)
+		}
+	}
+	if err := app.Commit(); err != nil {
+		t.Fatalf("unexpected error from Commit: %s", err)
+	}
+
+	expect := []labels.Labels{
+		labels.FromStrings("__name__", "up", "host", "node", "instance", "node:9100"),
+	}
+	if series := next.Series(); !reflect.DeepEqual(series, expect) {
+		t.Fatalf("expected series %v, got %v", expect, series)
+	}
+}
+
+func TestMetricsRelabelComponent_UnknownReceiver(t *testing.T) {
+	c := newMetricsRelabelComponent("metrics_relabel.test", componentOptions{
+		Logger:   log.NewNopLogger(),
+		Receiver: func(string) (storage.Appendable, bool) { return nil, false },
+	})
+
+	err := c.Update(config.MetricsRelabel{ForwardTo: []string{"remote_write.missing"}})
+	if expect := `forward_to: "remote_write.missing" is not a component that can receive metrics`; err == nil || err.Error() != expect {
+		t.Fatalf("expected error %q, got %v", expect, err)
+	}
+}
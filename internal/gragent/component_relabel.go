@@ -0,0 +1,180 @@
+package gragent
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/rfratto/gragent/internal/config"
+)
+
+func init() {
+	register(registration{
+		Name:   "relabel",
+		Labels: 1,
+		Args:   config.Relabel{},
+		State:  relabelState{},
+		Build: func(id string, o componentOptions) component {
+			return &relabelComponent{id: id}
+		},
+	})
+}
+
+// relabelState is the state exported by relabel components.
+type relabelState struct {
+	Targets []config.TargetGroup `hcl:"targets" cty:"targets"`
+}
+
+// relabelComponent applies relabeling rules to a set of targets. Relabeling
+// happens during evaluation, so there's nothing to do while running.
+type relabelComponent struct {
+	id string
+
+	mut     sync.Mutex
+	targets []config.TargetGroup
+}
+
+func (c *relabelComponent) Name() string { return c.id }
+
+func (c *relabelComponent) Update(args interface{}) error {
+	cfg := args.(config.Relabel)
+
+	rcs, err := buildRelabelConfigs(cfg.Rules)
+	if err != nil {
+		return err
+	}
+	targets := relabelTargets(cfg.Input, rcs)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.targets = targets
+	return nil
+}
+
+// relabelTargets applies rcs to every target in groups. Like Prometheus,
+// targets are relabeled with the labels of their group merged in, so
+// returned groups hold all labels on their targets. Targets which are
+// dropped by rcs are removed.
+func relabelTargets(groups []config.TargetGroup, rcs []*relabel.Config) []config.TargetGroup {
+	finalGroups := make([]config.TargetGroup, 0, len(groups))
+	for _, group := range groups {
+		finalGroup := config.TargetGroup{
+			Targets: make([]config.LabelSet, 0, len(group.Targets)),
+			Labels:  make(config.LabelSet),
+			Source:  group.Source,
+		}
+
+		for _, target := range group.Targets {
+			merged := make(map[string]string, len(group.Labels)+len(target))
+			for key, value := range group.Labels {
+				merged[key] = value
+			}
+			for key, value := range target {
+				merged[key] = value
+			}
+
+			lset := relabel.Process(labels.FromMap(merged), rcs...)
+			if lset == nil {
+				continue
+			}
+			finalGroup.Targets = append(finalGroup.Targets, config.LabelSet(lset.Map()))
+		}
+
+		if len(finalGroup.Targets) > 0 {
+			finalGroups = append(finalGroups, finalGroup)
+		}
+	}
+	return finalGroups
+}
+
+func (c *relabelComponent) CurrentState() interface{} {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	state := relabelState{Targets: c.targets}
+	if state.Targets == nil {
+		state.Targets = make([]config.TargetGroup, 0)
+	}
+	return &state
+}
+
+func (c *relabelComponent) Run(ctx context.Context, onStateChange func()) {
+	<-ctx.Done()
+}
+
+// relabelTargetPattern matches valid values for target_label, which may
+// reference regex capture groups.
+var relabelTargetPattern = regexp.MustCompile(`^(?:(?:[a-zA-Z_]|\$(?:\{\w+\}|\w+))+\w*)+$`)
+
+// buildRelabelConfigs converts rules into the upstream type. Rules are
+// checked the same way relabel.Config checks them when unmarshaling YAML, and
+// errors are prefixed with the 1-indexed position of the rule.
+func buildRelabelConfigs(rules []config.RelabelConfig) ([]*relabel.Config, error) {
+	rcs := make([]*relabel.Config, 0, len(rules))
+	for i, rule := range rules {
+		rc, err := buildRelabelConfig(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		rcs = append(rcs, rc)
+	}
+	return rcs, nil
+}
+
+func buildRelabelConfig(rule config.RelabelConfig) (*relabel.Config, error) {
+	rc := relabel.DefaultRelabelConfig
+
+	if rule.Action != "" {
+		rc.Action = relabel.Action(strings.ToLower(rule.Action))
+	}
+	switch rc.Action {
+	case relabel.Replace, relabel.Keep, relabel.Drop, relabel.HashMod, relabel.LabelMap, relabel.LabelDrop, relabel.LabelKeep:
+	default:
+		return nil, fmt.Errorf("unknown relabel action %q", rule.Action)
+	}
+
+	for _, name := range rule.SourceLabels {
+		rc.SourceLabels = append(rc.SourceLabels, model.LabelName(name))
+	}
+	if rule.Separator != nil {
+		rc.Separator = *rule.Separator
+	}
+	if rule.Regex != nil {
+		re, err := relabel.NewRegexp(*rule.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		rc.Regex = re
+	}
+	if rule.Replacement != nil {
+		rc.Replacement = *rule.Replacement
+	}
+	rc.Modulus = rule.Modulus
+	rc.TargetLabel = rule.TargetLabel
+
+	switch {
+	case rc.Action == relabel.HashMod && rc.Modulus == 0:
+		return nil, fmt.Errorf("hashmod action requires non-zero modulus")
+	case (rc.Action == relabel.Replace || rc.Action == relabel.HashMod) && rc.TargetLabel == "":
+		return nil, fmt.Errorf("%s action requires target_label", rc.Action)
+	case rc.Action == relabel.Replace && !relabelTargetPattern.MatchString(rc.TargetLabel):
+		return nil, fmt.Errorf("%q is invalid target_label for %s action", rc.TargetLabel, rc.Action)
+	case rc.Action == relabel.LabelMap && !relabelTargetPattern.MatchString(rc.Replacement):
+		return nil, fmt.Errorf("%q is invalid replacement for %s action", rc.Replacement, rc.Action)
+	case rc.Action == relabel.HashMod && !model.LabelName(rc.TargetLabel).IsValid():
+		return nil, fmt.Errorf("%q is invalid target_label for %s action", rc.TargetLabel, rc.Action)
+	}
+
+	if rc.Action == relabel.LabelDrop || rc.Action == relabel.LabelKeep {
+		if rule.SourceLabels != nil || rule.Separator != nil || rule.Replacement != nil || rule.Modulus != 0 || rule.TargetLabel != "" {
+			return nil, fmt.Errorf("%s action requires only regex, and no other fields", rc.Action)
+		}
+	}
+
+	return &rc, nil
+}
@@ -0,0 +1,130 @@
+package gragent
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rfratto/gragent/internal/config"
+)
+
+func TestRelabelComponent(t *testing.T) {
+	c := &relabelComponent{id: "relabel.test"}
+	err := c.Update(config.Relabel{
+		Input: []config.TargetGroup{
+			{
+				Source: "a",
+				Labels: config.LabelSet{"env": "prod"},
+				Targets: []config.LabelSet{
+					{"__address__": "a-0:80", "team": "infra"},
+					{"__address__": "a-1:80", "team": "web"},
+					{"__address__": "a-2:80", "team": "web", "canary": "true"},
+				},
+			},
+			{
+				Source:  "b",
+				Labels:  config.LabelSet{"env": "dev"},
+				Targets: []config.LabelSet{{"__address__": "b-0:80", "team": "web"}},
+			},
+		},
+		Rules: []config.RelabelConfig{
+			{SourceLabels: []string{"env"}, Regex: stringPtr("prod"), Action: "keep"},
+			{SourceLabels: []string{"canary"}, Regex: stringPtr("true"), Action: "drop"},
+			{SourceLabels: []string{"team", "env"}, Separator: stringPtr("-"), TargetLabel: "job", Replacement: stringPtr("${1}"), Regex: stringPtr("(.*)")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error from Update: %s", err)
+	}
+
+	// Group labels are moved onto targets, and groups left without targets
+	// are removed.
+	expect := []config.TargetGroup{{
+		Source: "a",
+		Labels: config.LabelSet{},
+		Targets: []config.LabelSet{
+			{"__address__": "a-0:80", "env": "prod", "team": "infra", "job": "infra-prod"},
+			{"__address__": "a-1:80", "env": "prod", "team": "web", "job": "web-prod"},
+		},
+	}}
+	if targets := c.CurrentState().(*relabelState).Targets; !reflect.DeepEqual(targets, expect) {
+		t.Fatalf("expected targets:\n%v\ngot:\n%v", expect, targets)
+	}
+}
+
+func TestBuildRelabelConfigs_Invalid(t *testing.T) {
+	tt := []struct {
+		name   string
+		rule   config.RelabelConfig
+		expect string
+	}{
+		{
+			name:   "unknown action",
+			rule:   config.RelabelConfig{Action: "rename"},
+			expect: `unknown relabel action "rename"`,
+		},
+		{
+			name:   "invalid regex",
+			rule:   config.RelabelConfig{Regex: stringPtr("("), TargetLabel: "job"},
+			expect: "invalid regex: error parsing regexp: missing closing ): `^(?:()$`",
+		},
+		{
+			name:   "hashmod without modulus",
+			rule:   config.RelabelConfig{Action: "hashmod", TargetLabel: "shard"},
+			expect: "hashmod action requires non-zero modulus",
+		},
+		{
+			name:   "replace without target_label",
+			rule:   config.RelabelConfig{Action: "replace"},
+			expect: "replace action requires target_label",
+		},
+		{
+			name:   "replace with invalid target_label",
+			rule:   config.RelabelConfig{TargetLabel: "job-name"},
+			expect: `"job-name" is invalid target_label for replace action`,
+		},
+		{
+			name:   "replace with target_label starting with a digit",
+			rule:   config.RelabelConfig{TargetLabel: "1job"},
+			expect: `"1job" is invalid target_label for replace action`,
+		},
+		{
+			name:   "labelmap with invalid replacement",
+			rule:   config.RelabelConfig{Action: "labelmap", Replacement: stringPtr("$1-name")},
+			expect: `"$1-name" is invalid replacement for labelmap action`,
+		},
+		{
+			// Unlike replace, hashmod doesn't expand capture groups.
+			name:   "hashmod with capture group target_label",
+			rule:   config.RelabelConfig{Action: "hashmod", Modulus: 2, TargetLabel: "${1}"},
+			expect: `"${1}" is invalid target_label for hashmod action`,
+		},
+		{
+			name:   "labeldrop with other fields",
+			rule:   config.RelabelConfig{Action: "labeldrop", Regex: stringPtr("tmp_.*"), TargetLabel: "job"},
+			expect: "labeldrop action requires only regex, and no other fields",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// Errors are reported against the position of the invalid rule.
+			valid := config.RelabelConfig{Action: "keep", SourceLabels: []string{"job"}}
+
+			_, err := buildRelabelConfigs([]config.RelabelConfig{valid, tc.rule})
+			if expect := "rule 2: " + tc.expect; err == nil || err.Error() != expect {
+				t.Fatalf("expected error %q, got %v", expect, err)
+			}
+		})
+	}
+}
+
+func TestBuildRelabelConfigs_TargetLabel(t *testing.T) {
+	for _, label := range []string{"job", "_job", "job_1", "${1}", "$1_job", "${name}_job", "job_${1}"} {
+		_, err := buildRelabelConfigs([]config.RelabelConfig{{TargetLabel: label}})
+		if err != nil {
+			t.Errorf("unexpected error for target_label %q: %s", label, err)
+		}
+	}
+}
+
+func stringPtr(s string) *string { return &s }
@@ -2,48 +2,120 @@ package gragent
 
 import (
 	"context"
+	"path/filepath"
+	"time"
 
-	"github.com/hashicorp/hcl/v2"
+	"github.com/go-kit/log"
+	common_config "github.com/prometheus/common/config"
+	promcfg "github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/rfratto/gragent/internal/components"
 	"github.com/rfratto/gragent/internal/config"
 )
 
-type remoteWriteBlock struct {
-	Name string `hcl:"name,label"`
+func init() {
+	register(registration{
+		Name:   "remote_write",
+		Labels: 1,
+		Args:   config.RemoteWrite{},
+		State:  remoteWriteState{},
+		Build: func(id string, o componentOptions) component {
+			return newRemoteWriteComponent(id, o)
+		},
+	})
+}
 
-	Body   hcl.Body `hcl:",body"`
-	Remain hcl.Body `hcl:",remain"`
+// remoteWriteState is the state exported by remote_write components.
+type remoteWriteState struct {
+	// Receiver is the ID of the component. Metrics sent to it through
+	// forward_to are written to its WAL and sent to the remote endpoint.
+	Receiver string `hcl:"receiver" cty:"receiver"`
 }
 
 type remoteWriteComponent struct {
 	id string
+	rw *components.RemoteWrite
 }
 
-func newRemoteWriteComponent(id string) *remoteWriteComponent {
+var (
+	_ statusComponent    = (*remoteWriteComponent)(nil)
+	_ storage.Appendable = (*remoteWriteComponent)(nil)
+)
+
+// newRemoteWriteComponent creates a new remote_write component. Its WAL is
+// stored in a directory named after the component inside of the data path.
+func newRemoteWriteComponent(id string, o componentOptions) *remoteWriteComponent {
+	walDir := filepath.Join(o.DataPath, id)
+
 	return &remoteWriteComponent{
 		id: id,
+		rw: components.NewRemoteWrite(log.With(o.Logger, "id", id), id, walDir),
 	}
 }
 
 func (c *remoteWriteComponent) Name() string { return c.id }
 
-func (c *remoteWriteComponent) Evaluate(ectx *hcl.EvalContext, b hcl.Body) (interface{}, hcl.Diagnostics) {
-	var cfg config.RemoteWrite
-
-	diags := config.DecodeHCL(ectx, b, &cfg)
-	if diags.HasErrors() {
-		return nil, diags
+func (c *remoteWriteComponent) Update(args interface{}) error {
+	rwc, err := c.buildRemoteWriteConfig(args.(config.RemoteWrite))
+	if err != nil {
+		return err
 	}
+	c.rw.Configure(rwc)
+	return nil
+}
 
-	// TODO(rfratto): do something
+// buildRemoteWriteConfig converts cfg into the upstream type.
+func (c *remoteWriteComponent) buildRemoteWriteConfig(cfg config.RemoteWrite) (*promcfg.RemoteWriteConfig, error) {
+	u, err := parseHTTPURL(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
 
-	return cfg, diags
+	rwc := promcfg.DefaultRemoteWriteConfig
+	rwc.Name = c.id
+	rwc.URL = &common_config.URL{URL: u}
+	return &rwc, nil
 }
 
 func (c *remoteWriteComponent) CurrentState() interface{} {
-	// There's no exposed state from remoteWriteComponent
-	return nil
+	state := remoteWriteState{Receiver: c.id}
+
+	return &state
+}
+
+// remoteWriteStatus is the debug-only status of remote_write components.
+type remoteWriteStatus struct {
+	Shards            int     `hcl:"shards" cty:"shards"`
+	PendingSamples    int     `hcl:"pending_samples" cty:"pending_samples"`
+	LastSendError     *string `hcl:"last_send_error" cty:"last_send_error"`
+	LastSendErrorTime *string `hcl:"last_send_error_time" cty:"last_send_error_time"`
+}
+
+func (c *remoteWriteComponent) CurrentStatus() interface{} {
+	rwStatus := c.rw.Status()
+
+	status := remoteWriteStatus{
+		Shards:         rwStatus.Shards,
+		PendingSamples: rwStatus.PendingSamples,
+	}
+	if rwStatus.LastSendError != nil {
+		var (
+			errText = rwStatus.LastSendError.Error()
+			ts      = rwStatus.LastSendErrorTime.Format(time.RFC3339Nano)
+		)
+		status.LastSendError = &errText
+		status.LastSendErrorTime = &ts
+	}
+
+	return &status
+}
+
+// Appender implements storage.Appendable. Appended samples are written to the
+// component's WAL.
+func (c *remoteWriteComponent) Appender(ctx context.Context) storage.Appender {
+	return c.rw.Appender(ctx)
 }
 
 func (c *remoteWriteComponent) Run(ctx context.Context, onStateChange func()) {
-	<-ctx.Done()
+	c.rw.Run(ctx, onStateChange)
 }
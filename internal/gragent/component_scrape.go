@@ -2,41 +2,91 @@ package gragent
 
 import (
 	"context"
+	"time"
 
-	"github.com/hashicorp/hcl/v2"
+	"github.com/go-kit/log"
+	promcfg "github.com/prometheus/prometheus/config"
+	"github.com/rfratto/gragent/internal/components"
 	"github.com/rfratto/gragent/internal/config"
 )
 
-type scrapeBlock struct {
-	Name string `hcl:"name,label"`
-
-	Body   hcl.Body `hcl:",body"`
-	Remain hcl.Body `hcl:",remain"`
+func init() {
+	register(registration{
+		Name:   "scrape",
+		Labels: 1,
+		Args:   config.MetricsScrape{},
+		Build: func(id string, o componentOptions) component {
+			return newScrapeComponent(id, o)
+		},
+	})
 }
 
 type scrapeComponent struct {
-	id string
+	id   string
+	opts componentOptions
+
+	fanout  *components.Fanout
+	scraper *components.Scrape
 }
 
-func newScrapeComponent(id string) *scrapeComponent {
+var _ statusComponent = (*scrapeComponent)(nil)
+
+func newScrapeComponent(id string, o componentOptions) *scrapeComponent {
+	fanout := &components.Fanout{}
+
 	return &scrapeComponent{
-		id: id,
+		id:   id,
+		opts: o,
+
+		fanout:  fanout,
+		scraper: components.NewScrape(log.With(o.Logger, "id", id), fanout),
 	}
 }
 
 func (c *scrapeComponent) Name() string { return c.id }
 
-func (c *scrapeComponent) Evaluate(ectx *hcl.EvalContext, b hcl.Body) (interface{}, hcl.Diagnostics) {
-	var cfg config.MetricsScrape
+func (c *scrapeComponent) Update(args interface{}) error {
+	cfg := args.(config.MetricsScrape)
+
+	sc, err := c.buildScrapeConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	receivers, err := resolveReceivers(c.opts, cfg.ForwardTo)
+	if err != nil {
+		return err
+	}
 
-	diags := config.DecodeHCL(ectx, b, &cfg)
-	if diags.HasErrors() {
-		return nil, diags
+	c.fanout.UpdateChildren(receivers)
+	c.scraper.Configure(components.ScrapeConfig{
+		Config:  sc,
+		Targets: toTargetGroups(cfg.Targets),
+	})
+	return nil
+}
+
+// buildScrapeConfig converts cfg into the upstream type. Settings which
+// aren't set by cfg default to the root-level settings.
+func (c *scrapeComponent) buildScrapeConfig(cfg config.MetricsScrape) (*promcfg.ScrapeConfig, error) {
+	var (
+		globals = c.opts.Globals()
+		sc      = promcfg.DefaultScrapeConfig
+	)
+
+	sc.JobName = cfg.JobName
+	if sc.JobName == "" {
+		sc.JobName = c.id
 	}
 
-	// TODO(rfratto): do something
+	settings, err := resolveScrapeSettings(cfg.ScrapeInterval, cfg.ScrapeTimeout, globals)
+	if err != nil {
+		return nil, err
+	}
+	sc.ScrapeInterval = settings.ScrapeInterval
+	sc.ScrapeTimeout = settings.ScrapeTimeout
 
-	return cfg, diags
+	return &sc, nil
 }
 
 func (c *scrapeComponent) CurrentState() interface{} {
@@ -44,6 +94,60 @@ func (c *scrapeComponent) CurrentState() interface{} {
 	return nil
 }
 
+// scrapeStatus is the debug-only status of scrape components.
+type scrapeStatus struct {
+	ActiveTargets  []scrapeActiveTarget  `hcl:"active_targets" cty:"active_targets"`
+	DroppedTargets []scrapeDroppedTarget `hcl:"dropped_targets" cty:"dropped_targets"`
+}
+
+// scrapeActiveTarget is a target which is being scraped.
+type scrapeActiveTarget struct {
+	URL                string            `hcl:"url" cty:"url"`
+	Labels             map[string]string `hcl:"labels" cty:"labels"`
+	Health             string            `hcl:"health" cty:"health"`
+	LastScrape         *string           `hcl:"last_scrape" cty:"last_scrape"`
+	LastScrapeDuration string            `hcl:"last_scrape_duration" cty:"last_scrape_duration"`
+	LastError          *string           `hcl:"last_error" cty:"last_error"`
+}
+
+// scrapeDroppedTarget is a target which was dropped by relabeling.
+type scrapeDroppedTarget struct {
+	DiscoveredLabels map[string]string `hcl:"discovered_labels" cty:"discovered_labels"`
+}
+
+func (c *scrapeComponent) CurrentStatus() interface{} {
+	active, dropped := c.scraper.Targets()
+
+	status := scrapeStatus{
+		ActiveTargets:  make([]scrapeActiveTarget, 0, len(active)),
+		DroppedTargets: make([]scrapeDroppedTarget, 0, len(dropped)),
+	}
+	for _, t := range active {
+		target := scrapeActiveTarget{
+			URL:                t.URL().String(),
+			Labels:             t.Labels().Map(),
+			Health:             string(t.Health()),
+			LastScrapeDuration: t.LastScrapeDuration().String(),
+		}
+		if lastScrape := t.LastScrape(); !lastScrape.IsZero() {
+			ts := lastScrape.Format(time.RFC3339Nano)
+			target.LastScrape = &ts
+		}
+		if err := t.LastError(); err != nil {
+			errText := err.Error()
+			target.LastError = &errText
+		}
+		status.ActiveTargets = append(status.ActiveTargets, target)
+	}
+	for _, t := range dropped {
+		status.DroppedTargets = append(status.DroppedTargets, scrapeDroppedTarget{
+			DiscoveredLabels: t.DiscoveredLabels().Map(),
+		})
+	}
+
+	return &status
+}
+
 func (c *scrapeComponent) Run(ctx context.Context, onStateChange func()) {
-	<-ctx.Done()
+	c.scraper.Run(ctx, onStateChange)
 }
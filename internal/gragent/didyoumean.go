@@ -0,0 +1,52 @@
+package gragent
+
+import (
+	"sort"
+
+	"github.com/agext/levenshtein"
+)
+
+// maxSuggestions is the maximum number of suggestions returned by
+// nameSuggestions.
+const maxSuggestions = 3
+
+// nameSuggestions returns the names from candidates which are most similar to
+// given, ordered from most to least similar. Names which aren't similar
+// enough to given are never suggested.
+func nameSuggestions(given string, candidates []string) []string {
+	type suggestion struct {
+		name string
+		dist int
+	}
+
+	// Longer names are allowed to be further away to catch typos in long
+	// component names. The minimum threshold of 3 is the same one used by HCL.
+	threshold := len(given) / 5
+	if threshold < 3 {
+		threshold = 3
+	}
+
+	var found []suggestion
+	for _, candidate := range candidates {
+		dist := levenshtein.Distance(given, candidate, nil)
+		if dist <= threshold {
+			found = append(found, suggestion{name: candidate, dist: dist})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].dist != found[j].dist {
+			return found[i].dist < found[j].dist
+		}
+		return found[i].name < found[j].name
+	})
+	if len(found) > maxSuggestions {
+		found = found[:maxSuggestions]
+	}
+
+	names := make([]string, 0, len(found))
+	for _, s := range found {
+		names = append(names, s.name)
+	}
+	return names
+}
@@ -0,0 +1,80 @@
+package gragent
+
+import (
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// fileWatcher watches the files read by components for changes. The
+// directories holding the files are watched rather than the files themselves
+// so files which are replaced or don't exist yet are still noticed.
+type fileWatcher struct {
+	log   log.Logger
+	w     *fsnotify.Watcher
+	files map[string]struct{}
+	dirs  map[string]struct{}
+}
+
+func newFileWatcher(l log.Logger) (*fileWatcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &fileWatcher{
+		log:   l,
+		w:     w,
+		files: make(map[string]struct{}),
+		dirs:  make(map[string]struct{}),
+	}, nil
+}
+
+// Sync updates the set of watched files. files must hold cleaned absolute
+// paths.
+func (fw *fileWatcher) Sync(files map[string]struct{}) {
+	dirs := make(map[string]struct{}, len(files))
+	for file := range files {
+		dirs[filepath.Dir(file)] = struct{}{}
+	}
+
+	for dir := range dirs {
+		if _, watched := fw.dirs[dir]; watched {
+			continue
+		}
+		if err := fw.w.Add(dir); err != nil {
+			// Don't remember the directory so adding it is retried on the next
+			// sync.
+			level.Warn(fw.log).Log("msg", "failed to watch directory", "dir", dir, "err", err)
+			delete(dirs, dir)
+		}
+	}
+	for dir := range fw.dirs {
+		if _, keep := dirs[dir]; keep {
+			continue
+		}
+		if err := fw.w.Remove(dir); err != nil {
+			level.Debug(fw.log).Log("msg", "failed to stop watching directory", "dir", dir, "err", err)
+		}
+	}
+
+	fw.files = files
+	fw.dirs = dirs
+}
+
+// Events returns the channel which receives events for every file in the
+// watched directories. Use Watched to filter out unwatched files.
+func (fw *fileWatcher) Events() <-chan fsnotify.Event { return fw.w.Events }
+
+// Errors returns the channel which receives errors from watching files.
+func (fw *fileWatcher) Errors() <-chan error { return fw.w.Errors }
+
+// Watched returns true if ev is for a watched file.
+func (fw *fileWatcher) Watched(ev fsnotify.Event) bool {
+	_, ok := fw.files[filepath.Clean(ev.Name)]
+	return ok
+}
+
+// Close stops watching files.
+func (fw *fileWatcher) Close() error { return fw.w.Close() }
@@ -0,0 +1,96 @@
+package gragent
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/rfratto/gragent/internal/dag"
+)
+
+const (
+	// localsBlock is the block type which defines local values.
+	localsBlock = "locals"
+
+	// localVariable is the name of the variable which holds local values.
+	// Local values are referenced as local.<name>.
+	localVariable = "local"
+)
+
+// localNode is a node for a single attribute of a locals block. Local values
+// are evaluated just like components, so they can reference components and
+// other local values, but they hold a single value and never run.
+type localNode struct {
+	name string
+	expr hcl.Expression
+}
+
+var _ dag.Node = (*localNode)(nil)
+
+// Name implements dag.Node.
+func (n *localNode) Name() string { return n.reference().String() }
+
+// reference returns the reference for n.
+func (n *localNode) reference() reference { return reference{localVariable, n.name} }
+
+// traversals returns all variable references from the expression of n.
+func (n *localNode) traversals() []hcl.Traversal {
+	return n.expr.(hclsyntax.Expression).Variables()
+}
+
+// decodeLocals returns a localNode for every attribute of the locals block
+// b. Local values must be unique across every locals block, so names which
+// are already in nodes are reported as duplicates.
+func decodeLocals(b *hcl.Block, nodes map[string]*localNode) ([]*localNode, hcl.Diagnostics) {
+	attrs, diags := b.Body.JustAttributes()
+
+	locals := make([]*localNode, 0, len(attrs))
+	for _, attr := range attrs {
+		if _, exist := nodes[attr.Name]; exist {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate local value",
+				Detail:   fmt.Sprintf("Local value %s is defined more than once", attr.Name),
+				Subject:  attr.NameRange.Ptr(),
+			})
+			continue
+		}
+
+		n := &localNode{name: attr.Name, expr: attr.Expr}
+		nodes[attr.Name] = n
+		locals = append(locals, n)
+	}
+	return locals, diags
+}
+
+// evaluateLocal evaluates the expression of n and caches its value into the
+// eval context for other nodes to reference. s.graphMut must be held when
+// calling evaluateLocal.
+func (s *System) evaluateLocal(n *localNode) (err error) {
+	var (
+		files = make(map[string]struct{})
+		ectx  = s.trackingEvalContext(files)
+	)
+
+	defer func() {
+		if info, ok := s.infoLookup[n]; ok {
+			info.LastEvalTime = time.Now()
+			info.LastEvalError = err
+			info.Files = files
+		}
+	}()
+
+	level.Debug(s.log).Log("msg", "evaluating local", "id", n.Name())
+
+	val, diags := n.expr.Value(ectx)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	s.inputLookup[n] = val
+	s.wctx.Set(n.reference(), val)
+	s.wctx.FillEvalContext(s.ectx)
+	return nil
+}
@@ -0,0 +1,126 @@
+package gragent
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/rfratto/gragent/internal/config"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// redactedValue replaces values which are hidden from the status.
+var redactedValue = cty.StringVal(config.Redacted)
+
+// sensitiveFuncs are functions which read values from outside of the config
+// file. Their results often hold credentials, so values computed from them
+// are hidden from the status.
+var sensitiveFuncs = map[string]struct{}{
+	"env":  {},
+	"file": {},
+}
+
+// redactor hides values of components and local values which may hold
+// secrets from the status. A redactor must only be used while s.graphMut is
+// held.
+type redactor struct {
+	s *System
+
+	// Cache of whether a local value is sensitive, keyed by name.
+	locals map[string]bool
+}
+
+func newRedactor(s *System) *redactor {
+	return &redactor{s: s, locals: make(map[string]bool)}
+}
+
+// redactArguments returns a copy of the evaluated arguments args of c where
+// every Secret field is redacted. Arguments and nested blocks with sensitive
+// expressions are redacted entirely.
+func (r *redactor) redactArguments(c component, args cty.Value) cty.Value {
+	if reg, ok := registrationFor(r.s.referenceMap[c]); ok {
+		args = config.RedactSecrets(args, reg.Args)
+	}
+
+	body, ok := r.s.bodyLookup[c].(*hclsyntax.Body)
+	if !ok || args.IsNull() || !args.Type().IsObjectType() {
+		return args
+	}
+
+	attrs := args.AsValueMap()
+	for name, attr := range body.Attributes {
+		if _, ok := attrs[name]; ok && r.sensitiveExpr(attr.Expr) {
+			attrs[name] = redactedValue
+		}
+	}
+	for _, block := range body.Blocks {
+		if _, ok := attrs[block.Type]; ok && r.sensitiveBody(block.Body) {
+			attrs[block.Type] = redactedValue
+		}
+	}
+	return cty.ObjectVal(attrs)
+}
+
+// sensitiveBody reports whether any expression in body or its nested blocks
+// is sensitive.
+func (r *redactor) sensitiveBody(body *hclsyntax.Body) bool {
+	for _, attr := range body.Attributes {
+		if r.sensitiveExpr(attr.Expr) {
+			return true
+		}
+	}
+	for _, block := range body.Blocks {
+		if r.sensitiveBody(block.Body) {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitiveExpr reports whether expr calls a function from sensitiveFuncs or
+// references a sensitive local value.
+func (r *redactor) sensitiveExpr(expr hclsyntax.Expression) bool {
+	var sensitive bool
+	_ = hclsyntax.VisitAll(expr, func(n hclsyntax.Node) hcl.Diagnostics {
+		if call, ok := n.(*hclsyntax.FunctionCallExpr); ok {
+			if _, found := sensitiveFuncs[call.Name]; found {
+				sensitive = true
+			}
+		}
+		return nil
+	})
+	if sensitive {
+		return true
+	}
+
+	for _, t := range expr.Variables() {
+		if t.RootName() != localVariable || len(t) < 2 {
+			continue
+		}
+		if attr, ok := t[1].(hcl.TraverseAttr); ok && r.sensitiveLocal(attr.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitiveLocal reports whether the expression of the local value name is
+// sensitive.
+func (r *redactor) sensitiveLocal(name string) bool {
+	if sensitive, ok := r.locals[name]; ok {
+		return sensitive
+	}
+
+	n, ok := r.s.localLookup[name]
+	if !ok {
+		return false
+	}
+	expr, ok := n.expr.(hclsyntax.Expression)
+	if !ok {
+		return false
+	}
+
+	// Local values can't reference themselves once loaded, but mark name as
+	// not sensitive while it's being checked so a cycle can't recurse forever.
+	r.locals[name] = false
+	r.locals[name] = r.sensitiveExpr(expr)
+	return r.locals[name]
+}
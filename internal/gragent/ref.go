@@ -32,20 +32,19 @@ func (r reference) String() string {
 	return strings.Join([]string(r), ".")
 }
 
-// TODO(rfratto): parsing references really doesn't need to be like this, we
-// can just parse by comparing to what exists.
-//
-// Note that references to elements in an array would still be more
+// TODO(rfratto): references to elements in an array would be more
 // complicated, but we're not there yet.
 
-// parseReference interprets the hcl.Traversal into a Reference. It supports parsing the
-// following references:
+// parseReference interprets the hcl.Traversal into a Reference. The root of
+// the traversal must be a block type known to the component registry, followed
+// by one attribute name for each label of that block type. For example:
 //
 //     discovery.<kind>.<name>
 //     scrape.<name>
 //     remote_write.<name>
 //
-// These align with the top-level blocks and labels known by root. The
+// These align with the blocks and labels of registered components. Local
+// values are referenced as local.<name> and arguments as argument.<name>. The
 // Traversal is only parsed up to these names; the remainder of the Traversal
 // is ignored.
 func parseReference(t hcl.Traversal) (reference, hcl.Diagnostics) {
@@ -54,129 +53,54 @@ func parseReference(t hcl.Traversal) (reference, hcl.Diagnostics) {
 		diags hcl.Diagnostics
 	)
 
-	switch split.RootName() {
-	case "discovery":
-		return parseDiscoveryRef(split.Rel, split.Abs.SourceRange())
-	case "scrape":
-		return parseScrapeRef(split.Rel, split.Abs.SourceRange())
-	case "remote_write":
-		return parseRemoteWriteRef(split.Rel, split.Abs.SourceRange())
-	default:
-		diags = diags.Append(&hcl.Diagnostic{
-			Severity: hcl.DiagError,
-			Summary:  "Invalid reference",
-			Detail:   fmt.Sprintf("%q is not a valid key name", split.RootName()),
-			Subject:  split.Abs.SourceRange().Ptr(),
-		})
-		return nil, diags
-	}
-}
-
-func parseDiscoveryRef(rel hcl.Traversal, startRange hcl.Range) (reference, hcl.Diagnostics) {
-	var (
-		kindAttr, nameAttr string
-		diags              hcl.Diagnostics
-	)
-
-	if len(rel) < 2 {
-		diags = diags.Append(&hcl.Diagnostic{
-			Severity: hcl.DiagError,
-			Summary:  "Invalid reference",
-			Detail:   `"discovery" must be followed by two attribute names: the discovery kind and name.`,
-			Subject:  startRange.Ptr(),
-		})
-		return nil, diags
-	}
-
-	switch tt := rel[0].(type) {
-	case hcl.TraverseAttr:
-		kindAttr = tt.Name
-	default:
-		diags = diags.Append(&hcl.Diagnostic{
-			Severity: hcl.DiagError,
-			Summary:  "Invalid reference",
-			Detail:   `The "discovery" object does not support this operation.`,
-			Subject:  rel[0].SourceRange().Ptr(),
-		})
-		return nil, diags
-	}
-
-	switch tt := rel[1].(type) {
-	case hcl.TraverseAttr:
-		nameAttr = tt.Name
-	default:
-		diags = diags.Append(&hcl.Diagnostic{
-			Severity: hcl.DiagError,
-			Summary:  "Invalid reference",
-			Detail:   `The "discovery" object does not support this operation.`,
-			Subject:  rel[1].SourceRange().Ptr(),
-		})
-		return nil, diags
-	}
-
-	return reference{"discovery", kindAttr, nameAttr}, nil
-}
-
-func parseScrapeRef(rel hcl.Traversal, startRange hcl.Range) (reference, hcl.Diagnostics) {
-	var (
-		nameAttr string
-		diags    hcl.Diagnostics
-	)
-
-	if len(rel) < 1 {
-		diags = diags.Append(&hcl.Diagnostic{
-			Severity: hcl.DiagError,
-			Summary:  "Invalid reference",
-			Detail:   `"scrape" must be followed by the name attribute.`,
-			Subject:  startRange.Ptr(),
-		})
-		return nil, diags
+	rootName := split.RootName()
+	labelNames, ok := blockLabelNames(rootName)
+	if rootName == localVariable || rootName == argumentBlock {
+		labelNames, ok = []string{"name"}, true
 	}
+	if !ok {
+		detail := fmt.Sprintf("%q is not a valid key name", rootName)
+		candidates := append(blockTypes(), globalsVariable, localVariable, argumentBlock)
+		if suggestions := nameSuggestions(rootName, candidates); len(suggestions) > 0 {
+			detail += fmt.Sprintf("; did you mean %q?", suggestions[0])
+		}
 
-	switch tt := rel[0].(type) {
-	case hcl.TraverseAttr:
-		nameAttr = tt.Name
-	default:
 		diags = diags.Append(&hcl.Diagnostic{
 			Severity: hcl.DiagError,
 			Summary:  "Invalid reference",
-			Detail:   `The "scrape" object does not support this operation.`,
-			Subject:  rel[0].SourceRange().Ptr(),
+			Detail:   detail,
+			Subject:  split.Abs.SourceRange().Ptr(),
 		})
 		return nil, diags
 	}
 
-	return reference{"scrape", nameAttr}, nil
-}
-
-func parseRemoteWriteRef(rel hcl.Traversal, startRange hcl.Range) (reference, hcl.Diagnostics) {
-	var (
-		nameAttr string
-		diags    hcl.Diagnostics
-	)
-
-	if len(rel) < 1 {
+	if len(split.Rel) < len(labelNames) {
 		diags = diags.Append(&hcl.Diagnostic{
 			Severity: hcl.DiagError,
 			Summary:  "Invalid reference",
-			Detail:   `"remote_write" must be followed by the name attribute.`,
-			Subject:  startRange.Ptr(),
+			Detail: fmt.Sprintf(
+				"%q must be followed by %d attribute names: %s.",
+				rootName, len(labelNames), strings.Join(labelNames, ", "),
+			),
+			Subject: split.Abs.SourceRange().Ptr(),
 		})
 		return nil, diags
 	}
 
-	switch tt := rel[0].(type) {
-	case hcl.TraverseAttr:
-		nameAttr = tt.Name
-	default:
-		diags = diags.Append(&hcl.Diagnostic{
-			Severity: hcl.DiagError,
-			Summary:  "Invalid reference",
-			Detail:   `The "remote_write" object does not support this operation.`,
-			Subject:  rel[0].SourceRange().Ptr(),
-		})
-		return nil, diags
+	ref := reference{rootName}
+	for _, step := range split.Rel[:len(labelNames)] {
+		tt, ok := step.(hcl.TraverseAttr)
+		if !ok {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid reference",
+				Detail:   fmt.Sprintf("The %q object does not support this operation.", rootName),
+				Subject:  step.SourceRange().Ptr(),
+			})
+			return nil, diags
+		}
+		ref = append(ref, tt.Name)
 	}
 
-	return reference{"remote_write", nameAttr}, nil
+	return ref, nil
 }
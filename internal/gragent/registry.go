@@ -0,0 +1,173 @@
+package gragent
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// registration describes a kind of component which can be defined in the
+// config file. Registrations are added to the registry by calling register
+// from an init function.
+type registration struct {
+	// Name is the dot-separated name of the component, such as
+	// "discovery.static". The first element of Name is the type of block which
+	// defines the component, and any remaining elements are block labels which
+	// must match exactly.
+	Name string
+
+	// Labels is the number of block labels following Name which uniquely
+	// identify an instance of the component, such as 1 for `scrape "name"`.
+	Labels int
+
+	// Args is the struct type that the component's block is decoded into.
+	// Values of Args must be usable with config.DecodeHCL and
+	// config.EncodeCty.
+	Args interface{}
+
+	// State is the struct type returned by the component's CurrentState, or
+	// nil if the component doesn't export any state. Values of State must be
+	// usable with config.EncodeCty, and State may not define any fields with
+	// the same name as a field in Args.
+	State interface{}
+
+	// Build creates a new instance of the component with the given ID.
+	Build func(id string, o componentOptions) component
+}
+
+// blockType returns the block type which defines components for r.
+func (r registration) blockType() string {
+	return strings.SplitN(r.Name, ".", 2)[0]
+}
+
+// fixedLabels returns the block labels from r's name which must match
+// exactly.
+func (r registration) fixedLabels() []string {
+	return strings.Split(r.Name, ".")[1:]
+}
+
+// newArgs returns a pointer to a new zero value of r's Args.
+func (r registration) newArgs() interface{} {
+	return reflect.New(reflect.TypeOf(r.Args)).Interface()
+}
+
+var registry = make(map[string]registration)
+
+// register adds r into the registry. register panics if r is invalid or
+// conflicts with an existing registration.
+func register(r registration) {
+	if _, exist := registry[r.Name]; exist {
+		panic(fmt.Sprintf("component %q registered twice", r.Name))
+	}
+	if r.Args == nil || r.Build == nil {
+		panic(fmt.Sprintf("component %q must have Args and Build", r.Name))
+	}
+	switch r.blockType() {
+	case globalsVariable, localVariable:
+		panic(fmt.Sprintf("component %q conflicts with the %q variable", r.Name, r.blockType()))
+	case localsBlock, argumentBlock:
+		panic(fmt.Sprintf("component %q conflicts with the %q block", r.Name, r.blockType()))
+	}
+
+	// HCL requires every block of the same type to have the same number of
+	// labels, so every registration with the same block type must agree.
+	for _, other := range registry {
+		if other.blockType() != r.blockType() {
+			continue
+		}
+		if len(other.fixedLabels()) != len(r.fixedLabels()) || other.Labels != r.Labels {
+			panic(fmt.Sprintf("component %q has different labels than %q", r.Name, other.Name))
+		}
+	}
+
+	// Component values are the combination of their args and state, so the
+	// two must not share any names.
+	if r.State != nil {
+		argsTy, err := gocty.ImpliedType(r.Args)
+		if err != nil {
+			panic(fmt.Sprintf("component %q has invalid Args: %s", r.Name, err))
+		}
+		stateTy, err := gocty.ImpliedType(r.State)
+		if err != nil {
+			panic(fmt.Sprintf("component %q has invalid State: %s", r.Name, err))
+		}
+		for name := range stateTy.AttributeTypes() {
+			if argsTy.HasAttribute(name) {
+				panic(fmt.Sprintf("component %q has state field %q which conflicts with args", r.Name, name))
+			}
+		}
+	}
+
+	registry[r.Name] = r
+}
+
+// registrationFor returns the registration for the component identified by
+// id.
+func registrationFor(id reference) (registration, bool) {
+	for i := len(id) - 1; i > 0; i-- {
+		r, ok := registry[id[:i].String()]
+		if ok && r.Labels == len(id)-i {
+			return r, true
+		}
+	}
+	return registration{}, false
+}
+
+// registrationForBlock returns the registration and component ID for the
+// given block.
+func registrationForBlock(b *hcl.Block) (registration, reference, bool) {
+	id := append(reference{b.Type}, b.Labels...)
+	r, ok := registrationFor(id)
+	return r, id, ok
+}
+
+// blockLabelNames returns the names of the labels for blocks of the given
+// type. ok will be false if no component is defined by blockType.
+func blockLabelNames(blockType string) (names []string, ok bool) {
+	for _, r := range registry {
+		if r.blockType() != blockType {
+			continue
+		}
+
+		for range r.fixedLabels() {
+			names = append(names, "kind")
+		}
+		for i := 0; i < r.Labels; i++ {
+			names = append(names, "name")
+		}
+		return names, true
+	}
+	return nil, false
+}
+
+// blockTypes returns the sorted set of block types which define components.
+func blockTypes() []string {
+	set := make(map[string]struct{})
+	for _, r := range registry {
+		set[r.blockType()] = struct{}{}
+	}
+
+	types := make([]string, 0, len(set))
+	for blockType := range set {
+		types = append(types, blockType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// componentSchema returns the schema for all blocks which define components.
+func componentSchema() *hcl.BodySchema {
+	var schema hcl.BodySchema
+	for _, blockType := range blockTypes() {
+		labelNames, _ := blockLabelNames(blockType)
+		schema.Blocks = append(schema.Blocks, hcl.BlockHeaderSchema{
+			Type:       blockType,
+			LabelNames: labelNames,
+		})
+	}
+	return &schema
+}
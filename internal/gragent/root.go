@@ -7,45 +7,106 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/hashicorp/hcl/v2"
-	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
+	promcfg "github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/storage"
 	"github.com/rfratto/gragent/internal/config"
 	"github.com/rfratto/gragent/internal/dag"
 	"github.com/rfratto/gragent/internal/dag/graphviz"
+	"github.com/rfratto/gragent/internal/funcs"
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/function"
-	"github.com/zclconf/go-cty/cty/function/stdlib"
 	"github.com/zclconf/go-cty/cty/gocty"
 )
 
-type rootBlock struct {
-	Discovery   []discoveryBlock   `hcl:"discovery,block"`
-	Scrape      []scrapeBlock      `hcl:"scrape,block"`
-	RemoteWrite []remoteWriteBlock `hcl:"remote_write,block"`
+// Options configures a System.
+type Options struct {
+	// ConfigFile is the path to the config file to load.
+	ConfigFile string
 
-	Body   hcl.Body `hcl:",body"`
-	Remain hcl.Body `hcl:",remain"`
+	// DataPath is the directory where components store data on disk, such as
+	// remote_write WALs.
+	DataPath string
+
+	// Arguments holds values for arguments declared in the config file, keyed
+	// by argument name. Values in Arguments override values from
+	// ArgumentsFile.
+	Arguments map[string]string
+
+	// ArgumentsFile is the path to an HCL or JSON file holding values for
+	// arguments declared in the config file. ArgumentsFile is read on every
+	// load.
+	ArgumentsFile string
+
+	// FunctionsVersion is the version of the function table available to
+	// expressions. Pinning a version keeps functions added in later versions
+	// from changing the meaning of a config. The latest version is used if
+	// FunctionsVersion is 0.
+	FunctionsVersion int
 }
 
 // System represents the gragent system.
 type System struct {
-	log        log.Logger
-	configFile string
+	log  log.Logger
+	opts Options
+
+	graphMut     sync.RWMutex
+	graph        *dag.Graph
+	idNodeMap    map[string]component   // Loaded components by ID
+	localLookup  map[string]*localNode  // Loaded local values by name
+	referenceMap map[dag.Node]reference // Reference for each loaded node
+	bodyLookup   map[dag.Node]hcl.Body  // Config body for each loaded component
+	inputLookup  map[dag.Node]cty.Value // Most recently evaluated inputs for each node
+	infoLookup   map[dag.Node]*evalInfo // Evaluation info for each node
+
+	ectx *hcl.EvalContext // Context used for evaluating nodes
+	wctx walkContext      // Cached values of evaluated nodes
+
+	globals globalSettings // Root-level settings from the most recent load
+
+	// parser is the parser used by the most recent load.
+	parser *hclparse.Parser
+
+	// reloaded is written to whenever Load swaps in a new graph to inform Run
+	// that the set of components may have changed.
+	reloaded chan struct{}
+
+	// changed holds the set of components which reported a state change and
+	// are waiting for their dependants to be re-evaluated. changedNotify is
+	// written to whenever a component is added to changed.
+	changedMut    sync.Mutex
+	changed       map[component]struct{}
+	changedNotify chan struct{}
 
-	graphMut sync.RWMutex
-	graph    *dag.Graph
+	// stale holds the set of nodes which read a file that changed and must be
+	// re-evaluated along with their dependants. Nodes are added to stale under
+	// changedMut and changedNotify is written to.
+	stale map[dag.Node]struct{}
 }
 
-func NewSystem(l log.Logger, configFile string) *System {
+// NewSystem creates a new, unloaded System. Call Load to load the config
+// file.
+func NewSystem(l log.Logger, o Options) *System {
 	s := &System{
-		log:        l,
-		configFile: configFile,
-		graph:      &dag.Graph{},
+		log:      l,
+		opts:     o,
+		graph:    &dag.Graph{},
+		reloaded: make(chan struct{}, 1),
+
+		changed:       make(map[component]struct{}),
+		changedNotify: make(chan struct{}, 1),
+		stale:         make(map[dag.Node]struct{}),
 	}
 	s.graph.Add(s) // Add the system as the root node.
 	return s
@@ -55,194 +116,736 @@ func NewSystem(l log.Logger, configFile string) *System {
 func (s *System) Name() string { return "<root>" }
 
 // Load reads the config file and updates the system to reflect what was
-// read.
+// read. Load may be called multiple times to reload the config file.
+//
+// Components are matched to the previous load by their ID. Components whose
+// ID still exists are kept and re-evaluated, components whose ID is new are
+// created, and components that no longer exist are removed from the graph.
+//
+// Attributes of locals blocks are added to the graph as local values, which
+// are evaluated alongside components. Arguments declared by argument blocks
+// are set from the values in Options before anything else is evaluated.
 func (s *System) Load() error {
 	s.graphMut.Lock()
 	defer s.graphMut.Unlock()
 
-	// TODO(rfratto): this won't work yet for subseqent loads.
+	if v := s.opts.FunctionsVersion; v < 0 || v > funcs.Version {
+		return fmt.Errorf("unsupported functions version %d, expecting 0 (latest) or a version from 1 to %d", v, funcs.Version)
+	}
 
-	bb, err := os.ReadFile(s.configFile)
+	bb, err := os.ReadFile(s.opts.ConfigFile)
 	if err != nil {
 		return fmt.Errorf("reading config file: %w", err)
 	}
 
-	file, diags := hclsyntax.ParseConfig(bb, s.configFile, hcl.InitialPos)
+	// Keep the parser around even if loading fails so the source of files can
+	// be shown alongside diagnostics.
+	s.parser = hclparse.NewParser()
+
+	file, diags := s.parser.ParseHCL(bb, s.opts.ConfigFile)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	// Split the file into the blocks which define components, local values, or
+	// arguments and the remaining root-level settings.
+	schema := componentSchema()
+	schema.Blocks = append(schema.Blocks,
+		hcl.BlockHeaderSchema{Type: localsBlock},
+		hcl.BlockHeaderSchema{Type: argumentBlock, LabelNames: []string{"name"}},
+	)
+
+	content, remain, contentDiags := file.Body.PartialContent(schema)
+	diags = diags.Extend(contentDiags)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	// Arguments and functions are the only things available to the root-level
+	// settings, so they must be decoded first.
+	argValues, adiags := readArgumentValues(s.opts)
+	diags = diags.Extend(adiags)
 	if diags.HasErrors() {
 		return diags
 	}
 
-	var root rootBlock
-	decodeDiags := gohcl.DecodeBody(file.Body, nil, &root)
-	diags = diags.Extend(decodeDiags)
+	var argBlocks []*hcl.Block
+	for _, block := range content.Blocks {
+		if block.Type == argumentBlock {
+			argBlocks = append(argBlocks, block)
+		}
+	}
+
+	// Argument defaults and root-level settings may read files, but aren't
+	// nodes in the graph, so the files they read aren't watched for changes.
+	// They're read again when the config is reloaded.
+	rootCtx := &hcl.EvalContext{Functions: s.functions()}
+	for name, fn := range s.fileFunctions(func(string) {}) {
+		rootCtx.Functions[name] = fn
+	}
+	arguments, adiags := decodeArguments(argBlocks, argValues, rootCtx)
+	diags = diags.Extend(adiags)
+	if diags.HasErrors() {
+		return diags
+	}
+	rootCtx.Variables = map[string]cty.Value{
+		argumentBlock: cty.ObjectVal(arguments),
+	}
+
+	globals, gdiags := decodeGlobals(remain, rootCtx)
+	diags = diags.Extend(gdiags)
 	if diags.HasErrors() {
 		return diags
 	}
 
-	// TODO(rfratto): persist between reloads?
+	// Build a new graph from scratch. The existing graph is only replaced once
+	// the new one is fully formed and evaluated, so a config that fails to load
+	// doesn't modify the running system.
 	var (
-		idNodeMap    = make(map[string]dag.Node)
+		graph        = &dag.Graph{}
+		idNodeMap    = make(map[string]component)
+		localLookup  = make(map[string]*localNode)
 		referenceMap = make(map[dag.Node]reference)
 		bodyLookup   = make(map[dag.Node]hcl.Body)
+		infoLookup   = make(map[dag.Node]*evalInfo)
 	)
-
-	// TODO(rfratto): we need to evaluate the remainder of the root block here
-	// for global settings.
+	graph.Add(s) // Add the system as the root node.
 
 	// Once we've parsed the config, we have to start creating components and
-	// populating our DAG.
-	//
-	// TODO(rfratto): match to existing components based on ID. We only have to
-	// make new components if the ID is new.
-	for _, disc := range root.Discovery {
-		var (
-			id    = reference{"discovery", disc.Kind, disc.Name}
-			idStr = id.String()
-		)
+	// populating our DAG. The component from the previous load is reused if
+	// one exists with the same ID, otherwise a new component is created.
+	for _, block := range content.Blocks {
+		if block.Type == argumentBlock {
+			// Already decoded.
+			continue
+		}
+		if block.Type == localsBlock {
+			locals, ldiags := decodeLocals(block, localLookup)
+			diags = diags.Extend(ldiags)
 
-		c := newDiscoveryComponent(disc.Kind, disc.Name)
-		s.graph.Add(c)
-		s.graph.AddEdge(dag.Edge{From: s, To: c})
+			for _, n := range locals {
+				graph.Add(n)
+				graph.AddEdge(dag.Edge{From: s, To: n})
+				referenceMap[n] = n.reference()
+				infoLookup[n] = &evalInfo{}
+			}
+			continue
+		}
 
-		idNodeMap[idStr] = c
-		referenceMap[c] = id
-		bodyLookup[c] = disc.Body
-	}
+		reg, id, ok := registrationForBlock(block)
+		if !ok {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unknown component",
+				Detail:   fmt.Sprintf("Block %s has unknown %s kind %q", id, block.Type, block.Labels[0]),
+				Subject:  block.DefRange.Ptr(),
+			})
+			continue
+		}
 
-	for _, scrape := range root.Scrape {
-		var (
-			id    = reference{"scrape", scrape.Name}
-			idStr = id.String()
-		)
+		idStr := id.String()
+		if _, exist := idNodeMap[idStr]; exist {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate component",
+				Detail:   fmt.Sprintf("Component %s is defined more than once", idStr),
+				Subject:  block.DefRange.Ptr(),
+			})
+			continue
+		}
 
-		c := newScrapeComponent(idStr)
-		s.graph.Add(c)
-		s.graph.AddEdge(dag.Edge{From: s, To: c})
+		c, ok := s.idNodeMap[idStr]
+		if !ok {
+			c = reg.Build(idStr, s.componentOptions())
+		}
+		graph.Add(c)
+		graph.AddEdge(dag.Edge{From: s, To: c})
 
 		idNodeMap[idStr] = c
 		referenceMap[c] = id
-		bodyLookup[c] = scrape.Body
-	}
+		bodyLookup[c] = block.Body
 
-	for _, rw := range root.RemoteWrite {
-		var (
-			id    = reference{"remote_write", rw.Name}
-			idStr = id.String()
-		)
+		// Keep the evaluation info of existing components so their state change
+		// times carry over between loads.
+		info, ok := s.infoLookup[c]
+		if !ok {
+			info = &evalInfo{}
+		}
+		infoLookup[c] = info
+	}
+	if diags.HasErrors() {
+		return diags
+	}
 
-		c := newRemoteWriteComponent(idStr)
-		s.graph.Add(c)
-		s.graph.AddEdge(dag.Edge{From: s, To: c})
+	// Source ranges of the references which formed each edge, used for
+	// reporting cycles.
+	edgeRanges := make(map[dag.Edge][]hcl.Range)
 
-		idNodeMap[idStr] = c
-		referenceMap[c] = id
-		bodyLookup[c] = rw.Body
+	// Every referenceable node by its ID.
+	nodeLookup := make(map[string]dag.Node, len(referenceMap))
+	for n, ref := range referenceMap {
+		nodeLookup[ref.String()] = n
 	}
 
+	traversalLookup := make(map[dag.Node][]hcl.Traversal, len(referenceMap))
 	for origin, body := range bodyLookup {
-		traversals := expressionsFromSyntaxBody(body.(*hclsyntax.Body))
+		traversalLookup[origin] = expressionsFromSyntaxBody(body.(*hclsyntax.Body))
+	}
+	for _, n := range localLookup {
+		traversalLookup[n] = n.traversals()
+	}
+
+	for origin, traversals := range traversalLookup {
 		for _, t := range traversals {
+			// Every node already depends on the system, which holds the global
+			// settings, so references to them don't need an edge.
+			if t.RootName() == globalsVariable {
+				continue
+			}
+
 			lookup, pdiags := parseReference(t)
-			diags.Extend(pdiags)
+			diags = diags.Extend(pdiags)
 			if lookup == nil {
 				continue
 			}
 
-			target := idNodeMap[lookup.String()]
-			if target != nil {
-				s.graph.AddEdge(dag.Edge{From: origin, To: target})
+			// Arguments never change after being decoded, so references to them
+			// don't need an edge either.
+			if lookup[0] == argumentBlock {
+				if _, ok := arguments[lookup[1]]; !ok {
+					diags = diags.Append(unknownArgumentDiagnostic(lookup, t, arguments))
+				}
+				continue
+			}
+
+			target := nodeLookup[lookup.String()]
+			if target == nil {
+				diags = diags.Append(unknownReferenceDiagnostic(lookup, t, nodeLookup))
+				continue
 			}
+
+			edge := dag.Edge{From: origin, To: target}
+			graph.AddEdge(edge)
+			edgeRanges[edge] = append(edgeRanges[edge], t.SourceRange())
 		}
 	}
 	if diags.HasErrors() {
 		return diags
 	}
 
+	// Components which are part of a cycle can never be evaluated, so we must
+	// reject the config.
+	for _, cycle := range dag.Cycles(graph) {
+		diags = diags.Extend(cycleDiagnostics(cycle, edgeRanges))
+	}
+	if diags.HasErrors() {
+		return diags
+	}
+
 	// Wiring dependencies probably caused a mess. Reduce to the minimum set of
 	// edges.
-	dag.Reduce(s.graph)
+	dag.Reduce(graph)
 
-	var (
-		wctx walkContext
-		ectx = hcl.EvalContext{
-			Variables: make(map[string]cty.Value),
-			Functions: map[string]function.Function{
-				"concat": stdlib.ConcatFunc,
+	// The new graph is fully formed; swap it in so it can be evaluated. Nodes
+	// are evaluated from scratch on every load, so nothing cached from the
+	// previous load is kept.
+	prev := s.swapState(loadedState{
+		graph:        graph,
+		idNodeMap:    idNodeMap,
+		localLookup:  localLookup,
+		referenceMap: referenceMap,
+		bodyLookup:   bodyLookup,
+		inputLookup:  make(map[dag.Node]cty.Value, len(referenceMap)),
+		infoLookup:   infoLookup,
+		globals:      globals,
+		ectx: &hcl.EvalContext{
+			Variables: map[string]cty.Value{
+				globalsVariable: globals.Value(),
+				argumentBlock:   cty.ObjectVal(arguments),
 			},
+			Functions: s.functions(),
+		},
+	})
+
+	// Perform a topological sort and evaluate everything. If anything fails to
+	// evaluate, the previous state is swapped back in and re-evaluated so that
+	// components kept from the previous load go back to their previous
+	// arguments. Run is only informed of the new set of components once the
+	// whole graph evaluated successfully.
+	if err := dag.WalkTopological(s.graph, s.evaluateNode); err != nil {
+		s.swapState(prev)
+		if rerr := dag.WalkTopological(s.graph, s.evaluateNode); rerr != nil {
+			level.Error(s.log).Log("msg", "failed to restore previous config", "err", rerr)
 		}
-	)
+		return err
+	}
 
-	// At this point, our DAG is completely formed and we can start to evaluate
-	// components. Perform a topological sort and evaluate everything.
-	err = dag.WalkTopological(s.graph, func(n dag.Node) error {
-		c, ok := n.(component)
-		if !ok {
-			// Not a component. Move on.
-			return nil
+	for id, c := range prev.idNodeMap {
+		if _, exist := idNodeMap[id]; !exist {
+			level.Debug(s.log).Log("msg", "removing component", "id", c.Name())
 		}
+	}
 
-		body, ok := bodyLookup[c]
-		if !ok {
-			return fmt.Errorf("unexpected missing hcl.Body for %s", n.Name())
+	s.notifyReloaded()
+	return nil
+}
+
+// loadedState is the state of a System built by a call to Load.
+type loadedState struct {
+	graph        *dag.Graph
+	idNodeMap    map[string]component
+	localLookup  map[string]*localNode
+	referenceMap map[dag.Node]reference
+	bodyLookup   map[dag.Node]hcl.Body
+	inputLookup  map[dag.Node]cty.Value
+	infoLookup   map[dag.Node]*evalInfo
+	globals      globalSettings
+
+	ectx *hcl.EvalContext
+	wctx walkContext
+}
+
+// swapState replaces the loaded state of s with st and returns the previous
+// state. s.graphMut must be held when calling swapState.
+func (s *System) swapState(st loadedState) loadedState {
+	prev := loadedState{
+		graph:        s.graph,
+		idNodeMap:    s.idNodeMap,
+		localLookup:  s.localLookup,
+		referenceMap: s.referenceMap,
+		bodyLookup:   s.bodyLookup,
+		inputLookup:  s.inputLookup,
+		infoLookup:   s.infoLookup,
+		globals:      s.globals,
+		ectx:         s.ectx,
+		wctx:         s.wctx,
+	}
+
+	s.graph = st.graph
+	s.idNodeMap = st.idNodeMap
+	s.localLookup = st.localLookup
+	s.referenceMap = st.referenceMap
+	s.bodyLookup = st.bodyLookup
+	s.inputLookup = st.inputLookup
+	s.infoLookup = st.infoLookup
+	s.globals = st.globals
+	s.ectx = st.ectx
+	s.wctx = st.wctx
+	return prev
+}
+
+// Files returns the files parsed by the most recent call to Load, keyed by
+// filename. Files can be used to show the source of diagnostics returned by
+// Load.
+func (s *System) Files() map[string]*hcl.File {
+	s.graphMut.RLock()
+	defer s.graphMut.RUnlock()
+
+	if s.parser == nil {
+		return nil
+	}
+	return s.parser.Files()
+}
+
+// functions returns the functions available to expressions at the version
+// of the function table from Options.
+func (s *System) functions() map[string]function.Function {
+	version := s.opts.FunctionsVersion
+	if version == 0 {
+		version = funcs.Version
+	}
+	return funcs.Functions(version)
+}
+
+// fileFunctions returns the functions which read files, if they're available
+// at the version of the function table from Options. onRead is invoked with
+// the path of every file read.
+func (s *System) fileFunctions(onRead func(path string)) map[string]function.Function {
+	version := s.opts.FunctionsVersion
+	if version != 0 && version < funcs.FileSince {
+		return nil
+	}
+	return map[string]function.Function{
+		"file": funcs.MakeFileFunc(filepath.Dir(s.opts.ConfigFile), onRead),
+	}
+}
+
+// componentOptions returns the options to pass to newly created components.
+func (s *System) componentOptions() componentOptions {
+	return componentOptions{
+		Logger:    s.log,
+		DataPath:  s.opts.DataPath,
+		ConfigDir: filepath.Dir(s.opts.ConfigFile),
+		Globals:   func() globalSettings { return s.globals },
+		Receiver:  s.receiver,
+	}
+}
+
+// receiver returns the storage.Appendable for the component identified by
+// id. s.graphMut must be held when calling receiver.
+func (s *System) receiver(id string) (storage.Appendable, bool) {
+	app, ok := s.idNodeMap[id].(storage.Appendable)
+	return app, ok
+}
+
+// decodeGlobals decodes and validates the root-level settings from body using
+// ectx. Settings which aren't defined default to the Prometheus defaults.
+func decodeGlobals(body hcl.Body, ectx *hcl.EvalContext) (globalSettings, hcl.Diagnostics) {
+	var (
+		cfg      config.Root
+		defaults = globalSettings{
+			ScrapeInterval: promcfg.DefaultGlobalConfig.ScrapeInterval,
+			ScrapeTimeout:  promcfg.DefaultGlobalConfig.ScrapeTimeout,
 		}
+	)
+
+	diags := config.DecodeHCL(ectx, body, &cfg)
+	if diags.HasErrors() {
+		return defaults, diags
+	}
+
+	globals, err := resolveScrapeSettings(cfg.ScrapeInterval, cfg.ScrapeTimeout, defaults)
+	if err != nil {
+		// Decoding succeeded, so the remaining body is known to only contain
+		// attributes. Look up the invalid one to report the error against its
+		// value.
+		var (
+			serr    = err.(*scrapeSettingError)
+			subject *hcl.Range
+		)
+		if attrs, _ := body.JustAttributes(); attrs[serr.Setting] != nil {
+			subject = attrs[serr.Setting].Expr.Range().Ptr()
+		}
+
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid " + serr.Setting,
+			Detail:   fmt.Sprintf("%s %s.", serr.Setting, serr.Err),
+			Subject:  subject,
+		})
+		return defaults, diags
+	}
+
+	return globals, diags
+}
+
+// evalInfo holds information about the evaluation of a component.
+type evalInfo struct {
+	LastEvalTime        time.Time // Last time the component was evaluated
+	LastEvalError       error     // Error from the last evaluation
+	LastStateChangeTime time.Time // Last time the component's state changed
+
+	// Files holds the absolute paths of files read through file() during the
+	// last evaluation.
+	Files map[string]struct{}
+}
+
+// evaluateNode evaluates n if it is a component or a local value. Other
+// nodes are ignored. s.graphMut must be held when calling evaluateNode.
+func (s *System) evaluateNode(n dag.Node) error {
+	switch n := n.(type) {
+	case component:
+		return s.evaluate(n)
+	case *localNode:
+		return s.evaluateLocal(n)
+	default:
+		// Nothing to evaluate. Move on.
+		return nil
+	}
+}
 
-		level.Debug(s.log).Log("msg", "evaluating node", "id", n.Name())
+// trackingEvalContext returns a child of s.ectx where file() records the
+// files it reads into files. Files are recorded even if reading them fails so
+// the evaluation is retried once they exist.
+func (s *System) trackingEvalContext(files map[string]struct{}) *hcl.EvalContext {
+	ectx := s.ectx.NewChild()
+	ectx.Functions = s.fileFunctions(func(path string) {
+		files[path] = struct{}{}
+	})
+	return ectx
+}
 
-		inputVal, ediags := c.Evaluate(&ectx, body)
-		if ediags.HasErrors() {
-			return ediags
+// evaluate evaluates c and caches its resulting value into the eval context
+// for other components to reference. s.graphMut must be held when calling
+// evaluate.
+func (s *System) evaluate(c component) (err error) {
+	var (
+		files = make(map[string]struct{})
+		ectx  = s.trackingEvalContext(files)
+	)
+
+	defer func() {
+		if info, ok := s.infoLookup[c]; ok {
+			info.LastEvalTime = time.Now()
+			info.LastEvalError = err
+			info.Files = files
 		}
-		inputCtyVal, err := config.EncodeCty(inputVal)
+	}()
+
+	body, ok := s.bodyLookup[c]
+	if !ok {
+		return fmt.Errorf("unexpected missing hcl.Body for %s", c.Name())
+	}
+
+	reg, ok := registrationFor(s.referenceMap[c])
+	if !ok {
+		return fmt.Errorf("unexpected missing registration for %s", c.Name())
+	}
+
+	level.Debug(s.log).Log("msg", "evaluating node", "id", c.Name())
+
+	args := reg.newArgs()
+	diags := config.DecodeHCL(ectx, body, args)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	// Dereference args so components receive the same type as their
+	// registration.
+	argsVal := reflect.ValueOf(args).Elem().Interface()
+
+	if err := c.Update(argsVal); err != nil {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid component arguments",
+			Detail:   fmt.Sprintf("Block %s has invalid arguments: %s", c.Name(), err),
+			Subject:  blockRange(body),
+		})
+		return diags
+	}
+
+	inputCtyVal, err := config.EncodeCty(argsVal)
+	if err != nil {
+		return err
+	}
+
+	s.inputLookup[c] = inputCtyVal
+	return s.updateValue(c)
+}
+
+// updateValue caches the value of c into the eval context. The cached value
+// is the combination of the most recently evaluated inputs of c with its
+// current state. s.graphMut must be held when calling updateValue.
+func (s *System) updateValue(c component) error {
+	cachedValue := s.inputLookup[c]
+
+	// Only query the state of components which declare that they have one.
+	reg, _ := registrationFor(s.referenceMap[c])
+	if stateVal := c.CurrentState(); reg.State != nil && stateVal != nil {
+		stateCtyVal, err := config.EncodeCty(stateVal)
 		if err != nil {
 			return err
 		}
+		cachedValue = mergeState(cachedValue, stateCtyVal)
+	}
+
+	s.wctx.Set(s.referenceMap[c], cachedValue)
+	s.wctx.FillEvalContext(s.ectx)
+	return nil
+}
 
-		cachedValue := inputCtyVal
+// onStateChange queues the dependants of c to be re-evaluated by Run. It is
+// invoked by running components whenever their state changes.
+func (s *System) onStateChange(c component) {
+	s.changedMut.Lock()
+	s.changed[c] = struct{}{}
+	s.changedMut.Unlock()
 
-		stateVal := c.CurrentState()
-		if stateVal != nil {
-			stateCtyVal, err := config.EncodeCty(stateVal)
-			if err != nil {
-				return err
-			}
-			cachedValue = mergeState(inputCtyVal, stateCtyVal)
+	select {
+	case s.changedNotify <- struct{}{}:
+	default:
+		// Something is already queued, don't need to do anything
+	}
+}
+
+// processStateChanges updates the cached values of all components which
+// reported a state change and re-evaluates every node which depends on them.
+// Stale nodes are re-evaluated along with their dependants.
+func (s *System) processStateChanges() {
+	s.changedMut.Lock()
+	changed, stale := s.changed, s.stale
+	s.changed = make(map[component]struct{})
+	s.stale = make(map[dag.Node]struct{})
+	s.changedMut.Unlock()
+
+	s.graphMut.Lock()
+	defer s.graphMut.Unlock()
+
+	var dependants []dag.Node
+	for n := range stale {
+		// Stale nodes are re-evaluated themselves, which will also re-evaluate
+		// their dependants.
+		if _, loaded := s.infoLookup[n]; loaded {
+			dependants = append(dependants, n)
+		}
+	}
+	for c := range changed {
+		if _, loaded := s.inputLookup[c]; !loaded {
+			// The component was either removed by a reload or it was never
+			// successfully evaluated. Either way, it has no value to update.
+			continue
 		}
 
-		wctx.vals = append(wctx.vals, referenceValue{
-			Key:   referenceMap[n],
-			Value: cachedValue,
-		})
-		wctx.FillEvalContext(&ectx)
+		s.infoLookup[c].LastStateChangeTime = time.Now()
+		if err := s.updateValue(c); err != nil {
+			level.Error(s.log).Log("msg", "failed to update component state", "id", c.Name(), "err", err)
+			continue
+		}
+		dependants = append(dependants, s.graph.Dependants(c)...)
+	}
+	if len(dependants) == 0 {
+		return
+	}
 
+	// Find everything which directly or indirectly depends on the changed
+	// components and re-evaluate them in dependency order.
+	reevaluate := make(map[dag.Node]struct{})
+	_ = dag.WalkReverse(s.graph, dependants, func(n dag.Node) error {
+		reevaluate[n] = struct{}{}
 		return nil
 	})
 
-	// TODO(rfratto): getting to the point now where we really need a /status
-	// endpoint to write everything as HCL back to the user.
-	//
-	// While doing this, we'll want to query the most recent state just so
-	// we get an up to date view, but we'll also want to include things like
-	// last_eval_time or last_emit_time alongside the status so users can tell if
-	// a component hasn't been updated just yet.
+	_ = dag.WalkTopological(s.graph, func(n dag.Node) error {
+		if _, ok := reevaluate[n]; !ok {
+			return nil
+		}
+		if err := s.evaluateNode(n); err != nil {
+			level.Error(s.log).Log("msg", "failed to re-evaluate node", "id", n.Name(), "err", err)
+		}
+		return nil
+	})
+}
 
-	return err
+// notifyReloaded informs Run that the set of components may have changed.
+func (s *System) notifyReloaded() {
+	select {
+	case s.reloaded <- struct{}{}:
+	default:
+		// Something is already queued, don't need to do anything
+	}
 }
 
 // Run runs the system. Run will block until there's an error or ctx is
 // canceled. The returned error will only be non-nil when there was an
 // error during running.
+//
+// Each loaded component is run in its own goroutine. Components are started
+// and stopped as they are added and removed from subsequent calls to Load.
+// When a running component reports that its state changed, every component
+// which depends on it is re-evaluated. When a file read by a component or
+// local value changes, it is re-evaluated along with its dependants.
 func (s *System) Run(ctx context.Context) error {
-	// TODO(rfratto): magic
-	<-ctx.Done()
-	return nil
+	watcher, err := newFileWatcher(s.log)
+	if err != nil {
+		return fmt.Errorf("creating file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	sched := newScheduler(ctx, s.log, s.onStateChange)
+	defer sched.Stop()
+
+	sched.Synchronize(s.loadedComponents())
+	watcher.Sync(s.readFiles())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.reloaded:
+			sched.Synchronize(s.loadedComponents())
+			watcher.Sync(s.readFiles())
+		case <-s.changedNotify:
+			s.processStateChanges()
+			watcher.Sync(s.readFiles())
+		case ev := <-watcher.Events():
+			if watcher.Watched(ev) {
+				s.onFileChange(ev.Name)
+			}
+		case err := <-watcher.Errors():
+			level.Warn(s.log).Log("msg", "error while watching files", "err", err)
+		}
+	}
+}
+
+// readFiles returns the set of files read by loaded nodes during their last
+// evaluation.
+func (s *System) readFiles() map[string]struct{} {
+	s.graphMut.RLock()
+	defer s.graphMut.RUnlock()
+
+	files := make(map[string]struct{})
+	for _, info := range s.infoLookup {
+		for file := range info.Files {
+			files[file] = struct{}{}
+		}
+	}
+	return files
+}
+
+// onFileChange marks every node which read path during its last evaluation
+// as stale.
+func (s *System) onFileChange(path string) {
+	path = filepath.Clean(path)
+
+	s.graphMut.RLock()
+	var stale []dag.Node
+	for n, info := range s.infoLookup {
+		if _, ok := info.Files[path]; ok {
+			stale = append(stale, n)
+		}
+	}
+	s.graphMut.RUnlock()
+
+	if len(stale) == 0 {
+		return
+	}
+	level.Debug(s.log).Log("msg", "file changed", "path", path, "nodes", len(stale))
+
+	s.changedMut.Lock()
+	for _, n := range stale {
+		s.stale[n] = struct{}{}
+	}
+	s.changedMut.Unlock()
+
+	select {
+	case s.changedNotify <- struct{}{}:
+	default:
+		// Something is already queued, don't need to do anything
+	}
+}
+
+// loadedComponents returns the set of components from the most recent load.
+func (s *System) loadedComponents() []component {
+	s.graphMut.RLock()
+	defer s.graphMut.RUnlock()
+
+	cc := make([]component, 0, len(s.idNodeMap))
+	for _, c := range s.idNodeMap {
+		cc = append(cc, c)
+	}
+	return cc
+}
+
+// ReloadHandler returns an http.Handler that reloads the config file.
+func (s *System) ReloadHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if err := s.Load(); err != nil {
+			level.Error(s.log).Log("msg", "failed to reload config file", "err", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		level.Info(s.log).Log("msg", "config file reloaded")
+		fmt.Fprintln(w, "config file reloaded")
+	}
 }
 
 // GraphHandler returns an http.Handler that renders the system's DAG as an
-// SVG.
+// SVG. Hovering over a node shows its evaluation info, and nodes which failed
+// their last evaluation are drawn in red.
 func (s *System) GraphHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, _ *http.Request) {
 		s.graphMut.RLock()
-		contents := dag.MarshalDOT(s.graph)
+		contents := dag.MarshalDOTAttributes(s.graph, s.graphAttributes)
 		s.graphMut.RUnlock()
 
 		svgBytes, err := graphviz.Dot(contents, "svg")
@@ -255,6 +858,40 @@ func (s *System) GraphHandler() http.HandlerFunc {
 	}
 }
 
+// graphAttributes returns the Graphviz attributes for n, showing the same
+// evaluation info as the status page. s.graphMut must be held when calling
+// graphAttributes.
+func (s *System) graphAttributes(n dag.Node) map[string]string {
+	info, ok := s.infoLookup[n]
+	if !ok {
+		return nil
+	}
+
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.Format(time.RFC3339Nano)
+	}
+	lastError := "none"
+	if info.LastEvalError != nil {
+		lastError = info.LastEvalError.Error()
+	}
+
+	attrs := map[string]string{
+		"tooltip": strings.Join([]string{
+			"last_eval_time: " + formatTime(info.LastEvalTime),
+			"last_state_change_time: " + formatTime(info.LastStateChangeTime),
+			"last_eval_error: " + lastError,
+		}, "\n"),
+	}
+	if info.LastEvalError != nil {
+		attrs["color"] = "red"
+		attrs["fontcolor"] = "red"
+	}
+	return attrs
+}
+
 // expressionsFromSyntaxBody returcses through body and finds all variable
 // references.
 func expressionsFromSyntaxBody(body *hclsyntax.Body) []hcl.Traversal {
@@ -270,6 +907,98 @@ func expressionsFromSyntaxBody(body *hclsyntax.Body) []hcl.Traversal {
 	return exprs
 }
 
+// unknownReferenceDiagnostic returns a diagnostic for the traversal t which
+// references a component or local value that doesn't exist. The diagnostic
+// suggests similarly named nodes from nodeLookup.
+func unknownReferenceDiagnostic(ref reference, t hcl.Traversal, nodeLookup map[string]dag.Node) *hcl.Diagnostic {
+	ids := make([]string, 0, len(nodeLookup))
+	for id := range nodeLookup {
+		ids = append(ids, id)
+	}
+
+	summary, detail := "Reference to unknown component", fmt.Sprintf("There is no component named %s.", ref)
+	if ref[0] == localVariable {
+		summary, detail = "Reference to unknown local value", fmt.Sprintf("There is no local value named %s.", ref[1])
+	}
+	if suggestions := nameSuggestions(ref.String(), ids); len(suggestions) > 0 {
+		detail += fmt.Sprintf(" Did you mean %s?", strings.Join(suggestions, ", or "))
+	}
+
+	// Only highlight the part of the traversal which names the component.
+	rng := hcl.Traversal(t[:len(ref)]).SourceRange()
+
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  summary,
+		Detail:   detail,
+		Subject:  &rng,
+	}
+}
+
+// unknownArgumentDiagnostic returns a diagnostic for the traversal t which
+// references an argument that isn't declared. The diagnostic suggests
+// similarly named arguments.
+func unknownArgumentDiagnostic(ref reference, t hcl.Traversal, arguments map[string]cty.Value) *hcl.Diagnostic {
+	names := make([]string, 0, len(arguments))
+	for name := range arguments {
+		names = append(names, name)
+	}
+
+	detail := fmt.Sprintf("There is no argument named %s.", ref[1])
+	if suggestions := nameSuggestions(ref[1], names); len(suggestions) > 0 {
+		detail += fmt.Sprintf(" Did you mean %s?", strings.Join(suggestions, ", or "))
+	}
+
+	rng := hcl.Traversal(t[:len(ref)]).SourceRange()
+
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Reference to unknown argument",
+		Detail:   detail,
+		Subject:  &rng,
+	}
+}
+
+// cycleDiagnostics returns a diagnostic for each reference which forms the
+// given cycle. edgeRanges holds the source ranges of the references which
+// formed each edge.
+func cycleDiagnostics(cycle []dag.Node, edgeRanges map[dag.Edge][]hcl.Range) hcl.Diagnostics {
+	names := make([]string, 0, len(cycle))
+	for _, n := range cycle {
+		names = append(names, n.Name())
+	}
+	sort.Strings(names)
+
+	var diags hcl.Diagnostics
+	for _, from := range cycle {
+		for _, to := range cycle {
+			for _, rng := range edgeRanges[dag.Edge{From: from, To: to}] {
+				rng := rng
+				diags = diags.Append(&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Cycle in component references",
+					Detail: fmt.Sprintf(
+						"%s references %s, forming a cycle between the following components: %s.",
+						from.Name(), to.Name(), strings.Join(names, ", "),
+					),
+					Subject: &rng,
+				})
+			}
+		}
+	}
+
+	// Sort diagnostics by their position in the file so they're reported in
+	// a consistent order.
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i].Subject, diags[j].Subject
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start.Byte < b.Start.Byte
+	})
+	return diags
+}
+
 // mergeState merges two the inputs of a component with its current state.
 // mergeState panics if a key exits in both inputs and store or if neither
 // argument is an object.
@@ -301,7 +1030,7 @@ func mergeState(inputs, state cty.Value) cty.Value {
 }
 
 type walkContext struct {
-	vals []referenceValue
+	vals map[string]referenceValue
 }
 
 type referenceValue struct {
@@ -309,6 +1038,15 @@ type referenceValue struct {
 	Value cty.Value
 }
 
+// Set caches the value for the given reference, replacing any value which
+// was previously cached.
+func (wc *walkContext) Set(key reference, value cty.Value) {
+	if wc.vals == nil {
+		wc.vals = make(map[string]referenceValue)
+	}
+	wc.vals[key.String()] = referenceValue{Key: key, Value: value}
+}
+
 // FillEvalContext fills an hcl.EvalContext with the referenceable values from
 // wc.
 func (wc *walkContext) FillEvalContext(ectx *hcl.EvalContext) {
@@ -0,0 +1,335 @@
+package gragent
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/rfratto/gragent/internal/config"
+	"github.com/rfratto/gragent/internal/dag"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+func TestLoad_Reload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.hcl")
+	s := NewSystem(log.NewNopLogger(), Options{ConfigFile: path})
+
+	writeFile(t, path, `
+discovery "static" "kept" {
+  hosts = ["kept:80"]
+}
+discovery "static" "removed" {
+  hosts = ["removed:80"]
+}
+`)
+	if err := s.Load(); err != nil {
+		t.Fatalf("unexpected error from first load: %s", err)
+	}
+	kept := s.idNodeMap["discovery.static.kept"]
+
+	writeFile(t, path, `
+discovery "static" "kept" {
+  hosts = ["changed:80"]
+}
+discovery "static" "added" {
+  hosts = ["added:80"]
+}
+`)
+	if err := s.Load(); err != nil {
+		t.Fatalf("unexpected error from second load: %s", err)
+	}
+
+	if ids := loadedIDs(s); !reflect.DeepEqual(ids, []string{"discovery.static.added", "discovery.static.kept"}) {
+		t.Fatalf("unexpected components after reload: %v", ids)
+	}
+	if s.idNodeMap["discovery.static.kept"] != kept {
+		t.Fatalf("expected discovery.static.kept to be reused")
+	}
+
+	var args config.DiscoveryStatic
+	componentArgs(t, s, "discovery.static.kept", &args)
+	if !reflect.DeepEqual(args.Hosts, []string{"changed:80"}) {
+		t.Fatalf("expected kept component to be re-evaluated, got hosts %v", args.Hosts)
+	}
+}
+
+// TestLoad_Rollback ensures that a config which fails to evaluate leaves the
+// previously loaded components and their arguments in place.
+func TestLoad_Rollback(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.hcl")
+	s := NewSystem(log.NewNopLogger(), Options{ConfigFile: path})
+
+	writeFile(t, path, `
+discovery "static" "kept" {
+  hosts = ["kept:80"]
+}
+`)
+	if err := s.Load(); err != nil {
+		t.Fatalf("unexpected error from first load: %s", err)
+	}
+	kept := s.idNodeMap["discovery.static.kept"]
+
+	// discovery.static.kept is updated with the new hosts before the chain
+	// fails to evaluate, so it must be reverted.
+	writeFile(t, path, `
+discovery "static" "kept" {
+  hosts = ["changed:80"]
+}
+discovery "chain" "invalid" {
+  input       = discovery.static.kept.targets
+  keep_labels = { job = "(" }
+}
+`)
+	if err := s.Load(); err == nil || !strings.Contains(err.Error(), "Invalid component arguments") {
+		t.Fatalf("expected second load to fail evaluating discovery.chain.invalid, got %v", err)
+	}
+
+	if ids := loadedIDs(s); !reflect.DeepEqual(ids, []string{"discovery.static.kept"}) {
+		t.Fatalf("unexpected components after failed reload: %v", ids)
+	}
+	if s.idNodeMap["discovery.static.kept"] != kept {
+		t.Fatalf("expected discovery.static.kept to be kept")
+	}
+
+	var args config.DiscoveryStatic
+	componentArgs(t, s, "discovery.static.kept", &args)
+	if !reflect.DeepEqual(args.Hosts, []string{"kept:80"}) {
+		t.Fatalf("expected kept component to be restored, got hosts %v", args.Hosts)
+	}
+}
+
+// loadedIDs returns the sorted IDs of the components loaded into s.
+func loadedIDs(s *System) []string {
+	s.graphMut.RLock()
+	defer s.graphMut.RUnlock()
+
+	ids := make([]string, 0, len(s.idNodeMap))
+	for id := range s.idNodeMap {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// componentArgs decodes the most recently evaluated arguments of the
+// component identified by id into out.
+func componentArgs(t *testing.T, s *System, id string, out interface{}) {
+	t.Helper()
+
+	s.graphMut.RLock()
+	defer s.graphMut.RUnlock()
+
+	val, ok := s.inputLookup[s.idNodeMap[id]]
+	if !ok {
+		t.Fatalf("component %s has not been evaluated", id)
+	}
+	if err := gocty.FromCtyValue(val, out); err != nil {
+		t.Fatalf("decoding arguments of %s: %s", id, err)
+	}
+}
+
+func TestLoad_FunctionsVersion(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.hcl"), `
+discovery "chain" "default" {
+  input = targets_from_hosts(["localhost"], 9090)
+}
+`)
+
+	tt := []struct {
+		version int
+		expect  string // Expected error substring, empty if Load should succeed
+	}{
+		{version: 0},
+		{version: 3},
+		{version: 2, expect: `There is no function named "targets_from_hosts"`},
+		{version: 4, expect: "unsupported functions version 4"},
+	}
+
+	for _, tc := range tt {
+		s := NewSystem(log.NewNopLogger(), Options{
+			ConfigFile:       filepath.Join(dir, "config.hcl"),
+			FunctionsVersion: tc.version,
+		})
+
+		err := s.Load()
+		switch {
+		case tc.expect == "" && err != nil:
+			t.Errorf("version %d: unexpected error: %s", tc.version, err)
+		case tc.expect != "" && (err == nil || !strings.Contains(err.Error(), tc.expect)):
+			t.Errorf("version %d: expected error containing %q, got %v", tc.version, tc.expect, err)
+		}
+	}
+}
+
+// TestLoad_FunctionsVersion_File ensures that file, which isn't part of the
+// function table, is only available from the version it was introduced in.
+func TestLoad_FunctionsVersion_File(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "hosts"), "localhost:9090")
+	writeFile(t, filepath.Join(dir, "component.hcl"), `
+discovery "static" "default" {
+  hosts = [file("hosts")]
+}
+`)
+	writeFile(t, filepath.Join(dir, "argument.hcl"), `
+argument "host" {
+  default = file("hosts")
+}
+`)
+
+	for _, name := range []string{"component.hcl", "argument.hcl"} {
+		for version, expect := range map[int]string{
+			0: "",
+			1: `There is no function named "file"`,
+			2: "",
+		} {
+			s := NewSystem(log.NewNopLogger(), Options{
+				ConfigFile:       filepath.Join(dir, name),
+				FunctionsVersion: version,
+			})
+
+			err := s.Load()
+			switch {
+			case expect == "" && err != nil:
+				t.Errorf("%s at version %d: unexpected error: %s", name, version, err)
+			case expect != "" && (err == nil || !strings.Contains(err.Error(), expect)):
+				t.Errorf("%s at version %d: expected error containing %q, got %v", name, version, expect, err)
+			}
+		}
+	}
+}
+
+// TestRun_StateChange ensures that a running component's state change is
+// propagated to the arguments of the components which depend on it.
+func TestRun_StateChange(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.hcl")
+	writeFile(t, path, `
+discovery "static" "upstream" {
+  hosts = ["upstream:80"]
+}
+discovery "chain" "downstream" {
+  input = discovery.static.upstream.targets
+}
+`)
+
+	s := NewSystem(log.NewNopLogger(), Options{ConfigFile: path})
+	if err := s.Load(); err != nil {
+		t.Fatalf("unexpected error from Load: %s", err)
+	}
+
+	// Discovery components have no targets until they run, so the chain is
+	// first evaluated with no input.
+	var args config.DiscoveryChain
+	componentArgs(t, s, "discovery.chain.downstream", &args)
+	if len(args.Input) != 0 {
+		t.Fatalf("expected no input before running, got %v", args.Input)
+	}
+
+	runSystem(t, s)
+
+	eventually(t, func() bool {
+		var args config.DiscoveryChain
+		componentArgs(t, s, "discovery.chain.downstream", &args)
+		return len(args.Input) == 1 && reflect.DeepEqual(args.Input[0].Targets, []config.LabelSet{
+			{"__address__": "upstream:80"},
+		})
+	})
+}
+
+// runSystem runs s until the test completes.
+func runSystem(t *testing.T, s *System) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		if err := s.Run(ctx); err != nil {
+			t.Errorf("unexpected error from Run: %s", err)
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-exited
+	})
+}
+
+// eventually fails the test if cond doesn't return true within a few
+// seconds.
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not satisfied in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestRun_FileChange ensures that components which read a file with file()
+// are re-evaluated when the file changes.
+func TestRun_FileChange(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "hosts"), "before:80")
+	writeFile(t, filepath.Join(dir, "config.hcl"), `
+discovery "static" "default" {
+  hosts = [file("hosts")]
+}
+`)
+
+	s := NewSystem(log.NewNopLogger(), Options{ConfigFile: filepath.Join(dir, "config.hcl")})
+	if err := s.Load(); err != nil {
+		t.Fatalf("unexpected error from Load: %s", err)
+	}
+	runSystem(t, s)
+
+	// Run watches files asynchronously, so keep rewriting the file until the
+	// change is picked up.
+	eventually(t, func() bool {
+		writeFile(t, filepath.Join(dir, "hosts"), "after:80")
+
+		var args config.DiscoveryStatic
+		componentArgs(t, s, "discovery.static.default", &args)
+		return reflect.DeepEqual(args.Hosts, []string{"after:80"})
+	})
+}
+
+func TestGraphAttributes(t *testing.T) {
+	s := NewSystem(log.NewNopLogger(), Options{})
+	c := &relabelComponent{id: "relabel.default"}
+	s.infoLookup = map[dag.Node]*evalInfo{
+		c: {
+			LastEvalTime:  time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC),
+			LastEvalError: errors.New("invalid arguments"),
+		},
+	}
+
+	expect := map[string]string{
+		"tooltip": "last_eval_time: 2021-11-01T12:00:00Z\n" +
+			"last_state_change_time: never\n" +
+			"last_eval_error: invalid arguments",
+		"color":     "red",
+		"fontcolor": "red",
+	}
+	if attrs := s.graphAttributes(c); !reflect.DeepEqual(attrs, expect) {
+		t.Fatalf("expected attributes %v, got %v", expect, attrs)
+	}
+
+	// Nodes without evaluation info, such as the system itself, have no
+	// attributes.
+	if attrs := s.graphAttributes(s); attrs != nil {
+		t.Fatalf("expected no attributes for the system, got %v", attrs)
+	}
+}
@@ -0,0 +1,127 @@
+package gragent
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/jpillora/backoff"
+)
+
+// scheduler runs a set of components. Each component runs in its own
+// goroutine and is restarted with a backoff if it exits before it was told to
+// stop.
+type scheduler struct {
+	log           log.Logger
+	onStateChange func(c component)
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running map[component]*runningComponent
+}
+
+// runningComponent is a component which is being run by a scheduler.
+type runningComponent struct {
+	cancel context.CancelFunc
+	exited chan struct{}
+}
+
+// newScheduler creates a new scheduler. Components will be run until ctx is
+// canceled or Stop is called. onStateChange is invoked any time a running
+// component reports that its state changed.
+func newScheduler(ctx context.Context, l log.Logger, onStateChange func(c component)) *scheduler {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &scheduler{
+		log:           l,
+		onStateChange: onStateChange,
+
+		ctx:     ctx,
+		cancel:  cancel,
+		running: make(map[component]*runningComponent),
+	}
+}
+
+// Synchronize synchronizes the set of running components with cc. Running
+// components which aren't in cc are stopped first, and Synchronize waits for
+// them to exit before starting the components in cc which aren't running.
+// This keeps a removed component and a new component with the same ID from
+// running at the same time and sharing resources like a WAL directory.
+func (s *scheduler) Synchronize(cc []component) {
+	keep := make(map[component]struct{}, len(cc))
+	for _, c := range cc {
+		keep[c] = struct{}{}
+	}
+
+	var stopped []*runningComponent
+	for c, rc := range s.running {
+		if _, ok := keep[c]; ok {
+			continue
+		}
+		level.Debug(s.log).Log("msg", "stopping component", "id", c.Name())
+		rc.cancel()
+		stopped = append(stopped, rc)
+		delete(s.running, c)
+	}
+	for _, rc := range stopped {
+		<-rc.exited
+	}
+
+	for _, c := range cc {
+		if _, running := s.running[c]; running {
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(s.ctx)
+		rc := &runningComponent{cancel: cancel, exited: make(chan struct{})}
+		s.running[c] = rc
+
+		go func(c component) {
+			defer close(rc.exited)
+			s.runComponent(ctx, c)
+		}(c)
+	}
+}
+
+// runComponent runs c until ctx is canceled, restarting it with a backoff
+// any time it exits early.
+func (s *scheduler) runComponent(ctx context.Context, c component) {
+	bo := backoff.Backoff{
+		Min: 100 * time.Millisecond,
+		Max: time.Minute,
+	}
+
+	onStateChange := func() { s.onStateChange(c) }
+
+	for {
+		level.Debug(s.log).Log("msg", "starting component", "id", c.Name())
+
+		started := time.Now()
+		c.Run(ctx, onStateChange)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Components that ran for a while before exiting are treated as a fresh
+		// failure instead of continuing to grow the backoff.
+		if time.Since(started) > bo.Max {
+			bo.Reset()
+		}
+
+		wait := bo.Duration()
+		level.Warn(s.log).Log("msg", "component exited early, restarting after backoff", "id", c.Name(), "backoff", wait)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Stop stops all running components and waits for them to exit.
+func (s *scheduler) Stop() {
+	s.cancel()
+	s.Synchronize(nil)
+}
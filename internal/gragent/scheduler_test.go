@@ -0,0 +1,159 @@
+package gragent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+// fakeComponent is a component which records how it's run. Unless exitEarly
+// is set, Run blocks until its context is canceled.
+type fakeComponent struct {
+	name      string
+	exitEarly bool          // Return from Run immediately
+	exitDelay time.Duration // Time to wait after ctx is canceled before exiting
+
+	// active is shared between fakeComponents which must never run at the
+	// same time, like components using the same WAL directory.
+	active *sharedResource
+
+	mut    sync.Mutex
+	starts int
+}
+
+// sharedResource counts how many fakeComponents are using it.
+type sharedResource struct {
+	mut      sync.Mutex
+	users    int
+	maxUsers int
+}
+
+func (r *sharedResource) acquire() {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.users++
+	if r.users > r.maxUsers {
+		r.maxUsers = r.users
+	}
+}
+
+func (r *sharedResource) release() {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.users--
+}
+
+func (c *fakeComponent) Name() string                  { return c.name }
+func (c *fakeComponent) Update(args interface{}) error { return nil }
+func (c *fakeComponent) CurrentState() interface{}     { return nil }
+
+func (c *fakeComponent) Run(ctx context.Context, onStateChange func()) {
+	c.mut.Lock()
+	c.starts++
+	c.mut.Unlock()
+
+	if c.exitEarly {
+		return
+	}
+
+	if c.active != nil {
+		c.active.acquire()
+		defer c.active.release()
+	}
+	<-ctx.Done()
+	time.Sleep(c.exitDelay)
+}
+
+func (c *fakeComponent) Starts() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.starts
+}
+
+func TestScheduler_Synchronize(t *testing.T) {
+	sched := newScheduler(context.Background(), log.NewNopLogger(), func(component) {})
+	defer sched.Stop()
+
+	var (
+		a = &fakeComponent{name: "a"}
+		b = &fakeComponent{name: "b"}
+	)
+
+	sched.Synchronize([]component{a, b})
+	waitForStarts(t, a, 1)
+	waitForStarts(t, b, 1)
+
+	// Components which are still in the set must keep running without being
+	// restarted.
+	sched.Synchronize([]component{a})
+	if _, running := sched.running[b]; running {
+		t.Errorf("expected b to be stopped")
+	}
+	if starts := a.Starts(); starts != 1 {
+		t.Errorf("expected a to be started once, got %d", starts)
+	}
+}
+
+// TestScheduler_Synchronize_Replace ensures that a removed component exits
+// before a new component with the same ID is started.
+func TestScheduler_Synchronize_Replace(t *testing.T) {
+	sched := newScheduler(context.Background(), log.NewNopLogger(), func(component) {})
+	defer sched.Stop()
+
+	var (
+		wal         = &sharedResource{}
+		old         = &fakeComponent{name: `remote_write.x`, active: wal, exitDelay: 50 * time.Millisecond}
+		replacement = &fakeComponent{name: `remote_write.x`, active: wal}
+	)
+
+	sched.Synchronize([]component{old})
+	waitForStarts(t, old, 1)
+
+	sched.Synchronize([]component{replacement})
+	waitForStarts(t, replacement, 1)
+
+	wal.mut.Lock()
+	defer wal.mut.Unlock()
+	if wal.maxUsers != 1 {
+		t.Fatalf("expected the old and new component to never run at the same time, got %d concurrent users", wal.maxUsers)
+	}
+}
+
+func TestScheduler_RestartsWithBackoff(t *testing.T) {
+	sched := newScheduler(context.Background(), log.NewNopLogger(), func(component) {})
+
+	c := &fakeComponent{name: "a", exitEarly: true}
+	sched.Synchronize([]component{c})
+
+	// The backoff starts at 100ms and doubles, so three starts take at least
+	// 300ms.
+	start := time.Now()
+	waitForStarts(t, c, 3)
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("expected restarts to be delayed by a backoff, restarted 3 times in %s", elapsed)
+	}
+
+	// Stopping must interrupt the backoff and prevent further restarts.
+	sched.Stop()
+	starts := c.Starts()
+	time.Sleep(500 * time.Millisecond)
+	if c.Starts() != starts {
+		t.Errorf("expected no restarts after Stop")
+	}
+}
+
+// waitForStarts waits for c to be started at least n times.
+func waitForStarts(t *testing.T, c *fakeComponent, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Starts() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %s to be started %d times, got %d", c.name, n, c.Starts())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
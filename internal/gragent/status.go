@@ -0,0 +1,162 @@
+package gragent
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/rfratto/gragent/internal/config"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// StatusHandler returns an http.Handler that writes the current status of
+// every component as HCL. Each component is written as a block containing
+// its evaluated arguments, its current state, and information about when it
+// was last evaluated and last changed state. Components which expose
+// debug-only status have it written into a nested status block. The values
+// of local values are written into a single locals block.
+//
+// Secrets, and values computed from the env or file functions, are written
+// as "(redacted)".
+func (s *System) StatusHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		s.graphMut.RLock()
+		f := s.statusFile()
+		s.graphMut.RUnlock()
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := f.WriteTo(w); err != nil {
+			level.Warn(s.log).Log("msg", "failed to write status", "err", err)
+		}
+	}
+}
+
+// statusFile builds an HCL file holding the status of every component.
+// s.graphMut must be held when calling statusFile.
+func (s *System) statusFile() *hclwrite.File {
+	ids := make([]string, 0, len(s.idNodeMap))
+	for id := range s.idNodeMap {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var (
+		f = hclwrite.NewEmptyFile()
+		r = newRedactor(s)
+	)
+	if len(s.localLookup) > 0 {
+		writeLocals(f.Body().AppendNewBlock(localsBlock, nil).Body(), s, r)
+		f.Body().AppendNewline()
+	}
+
+	for i, id := range ids {
+		if i > 0 {
+			f.Body().AppendNewline()
+		}
+
+		var (
+			c   = s.idNodeMap[id]
+			ref = s.referenceMap[c]
+		)
+
+		block := f.Body().AppendNewBlock(ref[0], ref[1:])
+		body := block.Body()
+
+		// Arguments from the most recent successful evaluation. Components which
+		// were never successfully evaluated have no arguments to write.
+		if args, ok := s.inputLookup[c]; ok {
+			writeObjectAttributes(body, r.redactArguments(c, args))
+		}
+
+		// Query the most recent state rather than using the cached value so the
+		// status is up to date.
+		if reg, _ := registrationFor(ref); reg.State != nil {
+			if state := c.CurrentState(); state != nil {
+				stateVal, err := config.EncodeCty(state)
+				if err != nil {
+					level.Warn(s.log).Log("msg", "failed to encode component state", "id", id, "err", err)
+				} else {
+					writeObjectAttributes(body, stateVal)
+				}
+			}
+		}
+
+		// Debug-only status, if the component has any.
+		if sc, ok := c.(statusComponent); ok {
+			statusVal, err := config.EncodeCty(sc.CurrentStatus())
+			if err != nil {
+				level.Warn(s.log).Log("msg", "failed to encode component status", "id", id, "err", err)
+			} else {
+				body.AppendNewline()
+				writeObjectAttributes(body.AppendNewBlock("status", nil).Body(), statusVal)
+			}
+		}
+
+		info := s.infoLookup[c]
+		body.AppendNewline()
+		body.SetAttributeValue("last_eval_time", timeValue(info.LastEvalTime))
+		body.SetAttributeValue("last_state_change_time", timeValue(info.LastStateChangeTime))
+		body.SetAttributeValue("last_eval_error", errorValue(info.LastEvalError))
+	}
+	return f
+}
+
+// writeLocals writes the most recently evaluated value of every local value
+// into body in sorted order. Local values which were never successfully
+// evaluated are written as null, and sensitive local values are redacted.
+// s.graphMut must be held when calling writeLocals.
+func writeLocals(body *hclwrite.Body, s *System, r *redactor) {
+	names := make([]string, 0, len(s.localLookup))
+	for name := range s.localLookup {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		val, ok := s.inputLookup[s.localLookup[name]]
+		if !ok {
+			val = cty.NullVal(cty.DynamicPseudoType)
+		} else if r.sensitiveLocal(name) {
+			val = redactedValue
+		}
+		body.SetAttributeValue(name, val)
+	}
+}
+
+// writeObjectAttributes writes each attribute of the object val into body in
+// sorted order.
+func writeObjectAttributes(body *hclwrite.Body, val cty.Value) {
+	if val.IsNull() || !val.Type().IsObjectType() {
+		return
+	}
+
+	valueMap := val.AsValueMap()
+	names := make([]string, 0, len(valueMap))
+	for name := range valueMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		body.SetAttributeValue(name, valueMap[name])
+	}
+}
+
+// timeValue converts t into an RFC3339 string. The zero time is converted
+// into null.
+func timeValue(t time.Time) cty.Value {
+	if t.IsZero() {
+		return cty.NullVal(cty.String)
+	}
+	return cty.StringVal(t.Format(time.RFC3339Nano))
+}
+
+// errorValue converts err into a string. A nil error is converted into null.
+func errorValue(err error) cty.Value {
+	if err == nil {
+		return cty.NullVal(cty.String)
+	}
+	return cty.StringVal(err.Error())
+}
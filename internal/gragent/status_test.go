@@ -0,0 +1,85 @@
+package gragent
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+// redactionConfig is loaded by TestStatusHandler_RedactsSecrets. Every value
+// containing "secret" must be redacted from the status.
+const redactionConfig = `
+locals {
+  token    = env("GRAGENT_TEST_SECRET")
+  header   = "Bearer ${local.token}"
+  hostname = "localhost"
+}
+
+discovery "http" "default" {
+  url          = "http://${local.hostname}:8080/sd"
+  bearer_token = "literal-secret"
+}
+
+discovery "consul" "default" {
+  server = "localhost:8500"
+  token  = local.header
+
+  basic_auth {
+    username = "user"
+    password = file("password")
+  }
+}
+`
+
+func TestStatusHandler_RedactsSecrets(t *testing.T) {
+	t.Setenv("GRAGENT_TEST_SECRET", "env-secret")
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "password"), "file-secret")
+	writeFile(t, filepath.Join(dir, "config.hcl"), redactionConfig)
+
+	status := loadStatus(t, filepath.Join(dir, "config.hcl"))
+	if strings.Contains(status, "secret") {
+		t.Errorf("status contains a secret:\n%s", status)
+	}
+	for _, expect := range []string{
+		`header   = "(redacted)"`,
+		`hostname = "localhost"`,
+		`bearer_token      = "(redacted)"`,
+		`basic_auth       = "(redacted)"`,
+		`server           = "localhost:8500"`,
+		`url               = "http://localhost:8080/sd"`,
+	} {
+		if !strings.Contains(status, expect) {
+			t.Errorf("expected status to contain %q:\n%s", expect, status)
+		}
+	}
+}
+
+// loadStatus loads the config file at path and returns the status of the
+// loaded components.
+func loadStatus(t *testing.T, path string) string {
+	t.Helper()
+
+	s := NewSystem(log.NewNopLogger(), Options{ConfigFile: path})
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.statusFile().WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,14 +2,26 @@ package discoveryext
 
 import (
 	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
 
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/discovery"
 	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/model/relabel"
 )
 
 // ChainConfig configures the Chain service discovery.
 type ChainConfig struct {
 	Input []*targetgroup.Group
+
+	// KeepLabels, when non-empty, only keeps targets where every label matches
+	// its regex. DropLabels removes targets where any label matches its regex.
+	// Labels which aren't set are matched as the empty string.
+	KeepLabels map[model.LabelName]relabel.Regexp
+	DropLabels map[model.LabelName]relabel.Regexp
 }
 
 var _ discovery.Config = (*ChainConfig)(nil)
@@ -22,23 +34,156 @@ func (c *ChainConfig) NewDiscoverer(o discovery.DiscovererOptions) (discovery.Di
 	return NewChainDiscoverer(c), nil
 }
 
+// ChainDiscoverer merges, dedupes, and filters a set of input target groups.
+// Its config may be changed while it is running.
 type ChainDiscoverer struct {
-	c *ChainConfig
+	mut     sync.Mutex
+	c       *ChainConfig
+	changed chan struct{}
 }
 
 var _ discovery.Discoverer = (*ChainDiscoverer)(nil)
 
 // NewChainDiscoverer returns a new ChainDiscoverer.
 func NewChainDiscoverer(c *ChainConfig) *ChainDiscoverer {
-	return &ChainDiscoverer{c: c}
+	return &ChainDiscoverer{
+		c:       c,
+		changed: make(chan struct{}, 1),
+	}
 }
 
-// Run runs the ChainDiscoverer.
-func (d *ChainDiscoverer) Run(ctx context.Context, up chan<- []*targetgroup.Group) {
+// ApplyConfig updates the config of d. ApplyConfig returns false if cfg isn't
+// a *ChainConfig.
+func (d *ChainDiscoverer) ApplyConfig(cfg discovery.Config) bool {
+	c, ok := cfg.(*ChainConfig)
+	if !ok {
+		return false
+	}
+
+	d.mut.Lock()
+	d.c = c
+	d.mut.Unlock()
+
 	select {
-	case <-ctx.Done():
-	case up <- d.c.Input:
+	case d.changed <- struct{}{}:
+	default:
+		// Something is already queued, don't need to do anything
+	}
+	return true
+}
+
+// Run runs the ChainDiscoverer. The merged set of groups is sent on startup
+// and then again every time the config changes the merged result.
+func (d *ChainDiscoverer) Run(ctx context.Context, up chan<- []*targetgroup.Group) {
+	var last []*targetgroup.Group
+
+	for first := true; ; first = false {
+		d.mut.Lock()
+		groups := mergeGroups(d.c)
+		d.mut.Unlock()
+
+		if first || !reflect.DeepEqual(groups, last) {
+			select {
+			case <-ctx.Done():
+				return
+			case up <- withRemovedGroups(groups, last):
+				last = groups
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.changed:
+		}
 	}
+}
+
+// mergeGroups merges the input groups of c by source. The labels of each
+// group are moved onto its targets so groups with the same source but
+// different labels can be combined. Targets which were already seen in any
+// group are removed, along with any targets rejected by the label filters.
+// Groups without a source are given one based on their index.
+//
+// Returned groups are sorted by source and never empty.
+func mergeGroups(c *ChainConfig) []*targetgroup.Group {
+	var (
+		bySource = make(map[string]*targetgroup.Group)
+		seen     = make(map[model.Fingerprint]struct{})
+	)
+
+	for i, group := range c.Input {
+		if group == nil {
+			continue
+		}
 
-	<-ctx.Done()
+		source := group.Source
+		if source == "" {
+			source = strconv.Itoa(i)
+		}
+
+		merged, ok := bySource[source]
+		if !ok {
+			merged = &targetgroup.Group{Source: source, Labels: model.LabelSet{}}
+			bySource[source] = merged
+		}
+
+		for _, target := range group.Targets {
+			lset := group.Labels.Merge(target)
+			if !keepTarget(c, lset) {
+				continue
+			}
+
+			fp := lset.Fingerprint()
+			if _, dupe := seen[fp]; dupe {
+				continue
+			}
+			seen[fp] = struct{}{}
+			merged.Targets = append(merged.Targets, lset)
+		}
+	}
+
+	groups := make([]*targetgroup.Group, 0, len(bySource))
+	for _, group := range bySource {
+		if len(group.Targets) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Source < groups[j].Source
+	})
+	return groups
+}
+
+// keepTarget returns true if lset passes the label filters of c.
+func keepTarget(c *ChainConfig, lset model.LabelSet) bool {
+	for name, re := range c.KeepLabels {
+		if !re.MatchString(string(lset[name])) {
+			return false
+		}
+	}
+	for name, re := range c.DropLabels {
+		if re.MatchString(string(lset[name])) {
+			return false
+		}
+	}
+	return true
+}
+
+// withRemovedGroups returns groups along with an empty group for every
+// source in prev that is no longer in groups. Receivers treat empty groups
+// as removed.
+func withRemovedGroups(groups, prev []*targetgroup.Group) []*targetgroup.Group {
+	current := make(map[string]struct{}, len(groups))
+	for _, group := range groups {
+		current[group.Source] = struct{}{}
+	}
+
+	result := append([]*targetgroup.Group{}, groups...)
+	for _, group := range prev {
+		if _, ok := current[group.Source]; !ok {
+			result = append(result, &targetgroup.Group{Source: group.Source})
+		}
+	}
+	return result
 }
@@ -0,0 +1,208 @@
+package discoveryext
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/model/relabel"
+)
+
+func TestMergeGroups(t *testing.T) {
+	tt := []struct {
+		name   string
+		config ChainConfig
+		expect []*targetgroup.Group
+	}{
+		{
+			name: "merge by source",
+			config: ChainConfig{Input: []*targetgroup.Group{
+				{
+					Source:  "b",
+					Labels:  model.LabelSet{"env": "prod"},
+					Targets: []model.LabelSet{{"__address__": "b-0:80"}},
+				},
+				{
+					Source:  "a",
+					Targets: []model.LabelSet{{"__address__": "a-0:80"}},
+				},
+				{
+					Source:  "b",
+					Labels:  model.LabelSet{"env": "dev"},
+					Targets: []model.LabelSet{{"__address__": "b-1:80"}},
+				},
+			}},
+			expect: []*targetgroup.Group{
+				{
+					Source:  "a",
+					Labels:  model.LabelSet{},
+					Targets: []model.LabelSet{{"__address__": "a-0:80"}},
+				},
+				{
+					Source: "b",
+					Labels: model.LabelSet{},
+					Targets: []model.LabelSet{
+						{"__address__": "b-0:80", "env": "prod"},
+						{"__address__": "b-1:80", "env": "dev"},
+					},
+				},
+			},
+		},
+		{
+			name: "missing sources use index",
+			config: ChainConfig{Input: []*targetgroup.Group{
+				{Targets: []model.LabelSet{{"__address__": "a:80"}}},
+				nil,
+				{Targets: []model.LabelSet{{"__address__": "b:80"}}},
+			}},
+			expect: []*targetgroup.Group{
+				{Source: "0", Labels: model.LabelSet{}, Targets: []model.LabelSet{{"__address__": "a:80"}}},
+				{Source: "2", Labels: model.LabelSet{}, Targets: []model.LabelSet{{"__address__": "b:80"}}},
+			},
+		},
+		{
+			// A target is a duplicate if its labels, including the labels of its
+			// group, match a target which was already seen.
+			name: "dedup by fingerprint",
+			config: ChainConfig{Input: []*targetgroup.Group{
+				{
+					Source:  "a",
+					Labels:  model.LabelSet{"env": "prod"},
+					Targets: []model.LabelSet{{"__address__": "host:80"}, {"__address__": "host:80"}},
+				},
+				{
+					Source:  "b",
+					Targets: []model.LabelSet{{"__address__": "host:80", "env": "prod"}},
+				},
+				{
+					Source:  "c",
+					Targets: []model.LabelSet{{"__address__": "host:80"}},
+				},
+			}},
+			expect: []*targetgroup.Group{
+				{Source: "a", Labels: model.LabelSet{}, Targets: []model.LabelSet{{"__address__": "host:80", "env": "prod"}}},
+				{Source: "c", Labels: model.LabelSet{}, Targets: []model.LabelSet{{"__address__": "host:80"}}},
+			},
+		},
+		{
+			name: "keep labels",
+			config: ChainConfig{
+				Input: []*targetgroup.Group{{
+					Source: "a",
+					Targets: []model.LabelSet{
+						{"__address__": "a:80", "env": "prod"},
+						{"__address__": "b:80", "env": "dev"},
+						{"__address__": "c:80"},
+					},
+				}},
+				KeepLabels: map[model.LabelName]relabel.Regexp{"env": relabel.MustNewRegexp("prod")},
+			},
+			expect: []*targetgroup.Group{
+				{Source: "a", Labels: model.LabelSet{}, Targets: []model.LabelSet{{"__address__": "a:80", "env": "prod"}}},
+			},
+		},
+		{
+			// Missing labels are matched as the empty string.
+			name: "drop labels",
+			config: ChainConfig{
+				Input: []*targetgroup.Group{{
+					Source: "a",
+					Targets: []model.LabelSet{
+						{"__address__": "a:80", "env": "prod"},
+						{"__address__": "b:80", "env": "dev"},
+						{"__address__": "c:80"},
+					},
+				}},
+				DropLabels: map[model.LabelName]relabel.Regexp{"env": relabel.MustNewRegexp("dev|")},
+			},
+			expect: []*targetgroup.Group{
+				{Source: "a", Labels: model.LabelSet{}, Targets: []model.LabelSet{{"__address__": "a:80", "env": "prod"}}},
+			},
+		},
+		{
+			name: "empty groups removed",
+			config: ChainConfig{
+				Input: []*targetgroup.Group{
+					{Source: "a", Targets: []model.LabelSet{{"__address__": "a:80", "env": "dev"}}},
+					{Source: "b"},
+				},
+				DropLabels: map[model.LabelName]relabel.Regexp{"env": relabel.MustNewRegexp("dev")},
+			},
+			expect: []*targetgroup.Group{},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			groups := mergeGroups(&tc.config)
+			if !reflect.DeepEqual(groups, tc.expect) {
+				t.Fatalf("expected groups %v, got %v", tc.expect, groups)
+			}
+		})
+	}
+}
+
+func TestChainDiscoverer_Run(t *testing.T) {
+	var (
+		a = &targetgroup.Group{Source: "a", Targets: []model.LabelSet{{"__address__": "a:80"}}}
+		b = &targetgroup.Group{Source: "b", Targets: []model.LabelSet{{"__address__": "b:80"}}}
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		d  = NewChainDiscoverer(&ChainConfig{Input: []*targetgroup.Group{a, b}})
+		up = make(chan []*targetgroup.Group)
+	)
+	go d.Run(ctx, up)
+
+	expectSources(t, recvGroups(t, up), "a", "b")
+
+	// Changing the config without changing the merged result must not emit
+	// anything.
+	d.ApplyConfig(&ChainConfig{Input: []*targetgroup.Group{b, a}})
+	select {
+	case groups := <-up:
+		t.Fatalf("unexpected groups for unchanged result: %v", groups)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Removed sources are sent as empty groups.
+	d.ApplyConfig(&ChainConfig{Input: []*targetgroup.Group{a}})
+	groups := recvGroups(t, up)
+	expectSources(t, groups, "a", "b")
+	if len(groups[1].Targets) != 0 {
+		t.Fatalf("expected removed group b to be empty, got %v", groups[1])
+	}
+}
+
+// recvGroups receives the next set of groups from up, failing the test if
+// nothing is received within a few seconds.
+func recvGroups(t *testing.T, up <-chan []*targetgroup.Group) []*targetgroup.Group {
+	t.Helper()
+
+	select {
+	case groups := <-up:
+		return groups
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for groups")
+		return nil
+	}
+}
+
+// expectSources fails the test if the sources of groups don't match sources.
+func expectSources(t *testing.T, groups []*targetgroup.Group, sources ...string) {
+	t.Helper()
+
+	actual := make([]string, 0, len(groups))
+	for _, group := range groups {
+		actual = append(actual, group.Source)
+	}
+	if !reflect.DeepEqual(actual, sources) {
+		t.Fatalf("expected sources %v, got %v", sources, actual)
+	}
+}
@@ -0,0 +1,196 @@
+package discoveryext
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery"
+	"github.com/prometheus/prometheus/discovery/refresh"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+const (
+	dnsNameLabel            = model.MetaLabelPrefix + "dns_name"
+	dnsSrvRecordPrefix      = model.MetaLabelPrefix + "dns_srv_record_"
+	dnsSrvRecordTargetLabel = dnsSrvRecordPrefix + "target"
+	dnsSrvRecordPortLabel   = dnsSrvRecordPrefix + "port"
+)
+
+// DefaultDNSConfig holds the default settings for DNSConfig, matching the
+// upstream DNS SD.
+var DefaultDNSConfig = DNSConfig{
+	RefreshInterval: model.Duration(30 * time.Second),
+	Type:            "SRV",
+}
+
+// Resolver looks up DNS records. *net.Resolver implements Resolver.
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*net.SRV, err error)
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+}
+
+// DNSConfig configures the DNS service discovery. It behaves like the
+// upstream DNS SD, but looks up records using Resolver, which may be replaced
+// to avoid using the network.
+//
+// The upstream DNS SD isn't wrapped because it queries servers directly with
+// github.com/miekg/dns, which fails to link against the golang.org/x/net
+// required by this module. Its queries also can't be redirected in tests.
+type DNSConfig struct {
+	Names           []string
+	RefreshInterval model.Duration
+	Type            string // One of SRV, A, or AAAA
+	Port            int    // Ignored for SRV records
+
+	// Resolver to use for lookups. net.DefaultResolver is used if nil.
+	Resolver Resolver
+}
+
+var _ discovery.Config = (*DNSConfig)(nil)
+
+// Name returns the name of the discovery.
+func (c *DNSConfig) Name() string { return "dns" }
+
+// Validate returns an error if c is invalid.
+func (c *DNSConfig) Validate() error {
+	if len(c.Names) == 0 {
+		return fmt.Errorf("DNS-SD config must contain at least one record name")
+	}
+	switch strings.ToUpper(c.Type) {
+	case "SRV":
+	case "A", "AAAA":
+		if c.Port == 0 {
+			return fmt.Errorf("a port is required in DNS-SD configs for all record types except SRV")
+		}
+	default:
+		return fmt.Errorf("invalid DNS-SD records type %s", c.Type)
+	}
+	return nil
+}
+
+// NewDiscoverer converts the DNSConfig into a Discoverer.
+func (c *DNSConfig) NewDiscoverer(o discovery.DiscovererOptions) (discovery.Discoverer, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return NewDNSDiscoverer(c, o.Logger), nil
+}
+
+// DNSDiscoverer periodically resolves a set of DNS names into targets.
+type DNSDiscoverer struct {
+	*refresh.Discovery
+
+	c        *DNSConfig
+	resolver Resolver
+	logger   log.Logger
+}
+
+var _ discovery.Discoverer = (*DNSDiscoverer)(nil)
+
+// NewDNSDiscoverer returns a new DNSDiscoverer.
+func NewDNSDiscoverer(c *DNSConfig, l log.Logger) *DNSDiscoverer {
+	if l == nil {
+		l = log.NewNopLogger()
+	}
+
+	var resolver Resolver = net.DefaultResolver
+	if c.Resolver != nil {
+		resolver = c.Resolver
+	}
+
+	d := &DNSDiscoverer{
+		c:        c,
+		resolver: resolver,
+		logger:   l,
+	}
+	d.Discovery = refresh.NewDiscovery(l, "dns", time.Duration(c.RefreshInterval), d.refresh)
+	return d
+}
+
+func (d *DNSDiscoverer) refresh(ctx context.Context) ([]*targetgroup.Group, error) {
+	var (
+		wg  sync.WaitGroup
+		mut sync.Mutex
+		tgs = make([]*targetgroup.Group, 0, len(d.c.Names))
+	)
+
+	wg.Add(len(d.c.Names))
+	for _, name := range d.c.Names {
+		go func(name string) {
+			defer wg.Done()
+
+			tg, err := d.refreshOne(ctx, name)
+			if err != nil {
+				if ctx.Err() == nil {
+					level.Error(d.logger).Log("msg", "Error refreshing DNS targets", "name", name, "err", err)
+				}
+				return
+			}
+
+			mut.Lock()
+			defer mut.Unlock()
+			tgs = append(tgs, tg)
+		}(name)
+	}
+
+	wg.Wait()
+	return tgs, ctx.Err()
+}
+
+// refreshOne resolves a single name into a target group.
+func (d *DNSDiscoverer) refreshOne(ctx context.Context, name string) (*targetgroup.Group, error) {
+	tg := &targetgroup.Group{Source: name}
+
+	hostPort := func(host string, port int) model.LabelValue {
+		return model.LabelValue(net.JoinHostPort(host, strconv.Itoa(port)))
+	}
+
+	switch strings.ToUpper(d.c.Type) {
+	case "SRV":
+		_, addrs, err := d.resolver.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			// Remove the final dot from rooted DNS names to make them look more
+			// usual.
+			host := strings.TrimRight(addr.Target, ".")
+
+			tg.Targets = append(tg.Targets, model.LabelSet{
+				model.AddressLabel:      hostPort(host, int(addr.Port)),
+				dnsNameLabel:            model.LabelValue(name),
+				dnsSrvRecordTargetLabel: model.LabelValue(addr.Target),
+				dnsSrvRecordPortLabel:   model.LabelValue(strconv.Itoa(int(addr.Port))),
+			})
+		}
+
+	case "A", "AAAA":
+		network := "ip4"
+		if strings.ToUpper(d.c.Type) == "AAAA" {
+			network = "ip6"
+		}
+
+		ips, err := d.resolver.LookupIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			tg.Targets = append(tg.Targets, model.LabelSet{
+				model.AddressLabel:      hostPort(ip.String(), d.c.Port),
+				dnsNameLabel:            model.LabelValue(name),
+				dnsSrvRecordTargetLabel: "",
+				dnsSrvRecordPortLabel:   "",
+			})
+		}
+	}
+
+	return tg, nil
+}
@@ -0,0 +1,159 @@
+package discoveryext
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+)
+
+// fakeResolver resolves names from fixed sets of records.
+type fakeResolver struct {
+	srv map[string][]*net.SRV
+	ips map[string][]net.IP // Keyed by network and host, such as "ip4/host"
+}
+
+func (r fakeResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	addrs, ok := r.srv[name]
+	if !ok {
+		return "", nil, fmt.Errorf("no such host %s", name)
+	}
+	return name, addrs, nil
+}
+
+func (r fakeResolver) LookupIP(_ context.Context, network, host string) ([]net.IP, error) {
+	ips, ok := r.ips[network+"/"+host]
+	if !ok {
+		return nil, fmt.Errorf("no such host %s", host)
+	}
+	return ips, nil
+}
+
+func TestDNSDiscoverer(t *testing.T) {
+	resolver := fakeResolver{
+		srv: map[string][]*net.SRV{
+			"_web._tcp.example.com": {
+				{Target: "web-0.example.com.", Port: 8080},
+				{Target: "web-1.example.com.", Port: 8081},
+			},
+		},
+		ips: map[string][]net.IP{
+			"ip4/a.example.com":    {net.ParseIP("10.0.0.1")},
+			"ip6/aaaa.example.com": {net.ParseIP("::1")},
+		},
+	}
+
+	tt := []struct {
+		name   string
+		config DNSConfig
+		expect []*targetgroup.Group
+	}{
+		{
+			name:   "SRV",
+			config: DNSConfig{Names: []string{"_web._tcp.example.com"}, Type: "SRV"},
+			expect: []*targetgroup.Group{{
+				Source: "_web._tcp.example.com",
+				Targets: []model.LabelSet{
+					{
+						"__address__":                  "web-0.example.com:8080",
+						"__meta_dns_name":              "_web._tcp.example.com",
+						"__meta_dns_srv_record_target": "web-0.example.com.",
+						"__meta_dns_srv_record_port":   "8080",
+					},
+					{
+						"__address__":                  "web-1.example.com:8081",
+						"__meta_dns_name":              "_web._tcp.example.com",
+						"__meta_dns_srv_record_target": "web-1.example.com.",
+						"__meta_dns_srv_record_port":   "8081",
+					},
+				},
+			}},
+		},
+		{
+			name:   "A",
+			config: DNSConfig{Names: []string{"a.example.com"}, Type: "A", Port: 9100},
+			expect: []*targetgroup.Group{{
+				Source: "a.example.com",
+				Targets: []model.LabelSet{{
+					"__address__":                  "10.0.0.1:9100",
+					"__meta_dns_name":              "a.example.com",
+					"__meta_dns_srv_record_target": "",
+					"__meta_dns_srv_record_port":   "",
+				}},
+			}},
+		},
+		{
+			name:   "AAAA",
+			config: DNSConfig{Names: []string{"aaaa.example.com"}, Type: "AAAA", Port: 9100},
+			expect: []*targetgroup.Group{{
+				Source: "aaaa.example.com",
+				Targets: []model.LabelSet{{
+					"__address__":                  "[::1]:9100",
+					"__meta_dns_name":              "aaaa.example.com",
+					"__meta_dns_srv_record_target": "",
+					"__meta_dns_srv_record_port":   "",
+				}},
+			}},
+		},
+		{
+			// Names which fail to resolve are logged and skipped.
+			name:   "unknown name",
+			config: DNSConfig{Names: []string{"missing.example.com"}, Type: "SRV"},
+			expect: []*targetgroup.Group{},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := tc.config
+			cfg.Resolver = resolver
+
+			d := NewDNSDiscoverer(&cfg, log.NewNopLogger())
+			groups, err := d.refresh(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(groups, tc.expect) {
+				t.Fatalf("expected groups %v, got %v", tc.expect, groups)
+			}
+		})
+	}
+}
+
+func TestDNSConfig_Validate(t *testing.T) {
+	tt := []struct {
+		name   string
+		config DNSConfig
+		expect string
+	}{
+		{
+			name:   "no names",
+			config: DNSConfig{Type: "SRV"},
+			expect: "DNS-SD config must contain at least one record name",
+		},
+		{
+			name:   "A without port",
+			config: DNSConfig{Names: []string{"example.com"}, Type: "A"},
+			expect: "a port is required in DNS-SD configs for all record types except SRV",
+		},
+		{
+			name:   "invalid type",
+			config: DNSConfig{Names: []string{"example.com"}, Type: "MX"},
+			expect: "invalid DNS-SD records type MX",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if err == nil || err.Error() != tc.expect {
+				t.Fatalf("expected error %q, got %v", tc.expect, err)
+			}
+		})
+	}
+}
@@ -1,12 +1,19 @@
 package promutils
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
 
 // Unregisterer is a Prometheus Registerer that can unregister all collectors
 // passed to it.
 type Unregisterer struct {
 	wrap prometheus.Registerer
-	cs   map[prometheus.Collector]struct{}
+
+	mut sync.Mutex
+	cs  map[prometheus.Collector]struct{}
 }
 
 // WrapWithUnregisterer wraps a prometheus Registerer with capabilities to
@@ -28,6 +35,9 @@ func (u *Unregisterer) Register(c prometheus.Collector) error {
 	if err != nil {
 		return err
 	}
+
+	u.mut.Lock()
+	defer u.mut.Unlock()
 	u.cs[c] = struct{}{}
 	return nil
 }
@@ -44,6 +54,8 @@ func (u *Unregisterer) MustRegister(cs ...prometheus.Collector) {
 // Unregister implements prometheus.Registerer.
 func (u *Unregisterer) Unregister(c prometheus.Collector) bool {
 	if u.wrap != nil && u.wrap.Unregister(c) {
+		u.mut.Lock()
+		defer u.mut.Unlock()
 		delete(u.cs, c)
 		return true
 	}
@@ -54,10 +66,35 @@ func (u *Unregisterer) Unregister(c prometheus.Collector) bool {
 // Reigsterer.
 func (u *Unregisterer) UnregisterAll() bool {
 	success := true
-	for c := range u.cs {
+	for _, c := range u.collectors() {
 		if !u.Unregister(c) {
 			success = false
 		}
 	}
 	return success
 }
+
+// Gather implements prometheus.Gatherer, gathering metrics from all
+// collectors registered through the Registerer. Gathered metrics will not
+// include any labels added by the wrapped Registerer.
+func (u *Unregisterer) Gather() ([]*dto.MetricFamily, error) {
+	reg := prometheus.NewRegistry()
+	for _, c := range u.collectors() {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return reg.Gather()
+}
+
+// collectors returns the current set of registered collectors.
+func (u *Unregisterer) collectors() []prometheus.Collector {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+
+	cs := make([]prometheus.Collector, 0, len(u.cs))
+	for c := range u.cs {
+		cs = append(cs, c)
+	}
+	return cs
+}